@@ -1,8 +1,102 @@
 package config
 
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	"github.com/IndexStorm/common-go/nanoid"
+	"github.com/caarlos0/env/v11"
+)
+
+const (
+	appVersionUnknown   = "unknown"
+	appVersionDevel     = "(devel)"
+	appVersionDirty     = "-dirty"
+	appRevisionShortLen = 12
+)
+
+// hostname is replaced in tests to exercise the random fallback.
+var hostname = os.Hostname
+
 type AppInfo struct {
 	Service   string
 	Namespace string
 	Version   string
 	Instance  string
 }
+
+type appInfoEnv struct {
+	Service   string `env:"APP_SERVICE,notEmpty"`
+	Namespace string `env:"APP_NAMESPACE"`
+	Version   string `env:"APP_VERSION"`
+	Instance  string `env:"APP_INSTANCE"`
+	PodName   string `env:"POD_NAME"`
+}
+
+// ParseAppInfo fills AppInfo from the environment and the binary build info.
+// Service and Namespace come from APP_SERVICE and APP_NAMESPACE. Version is taken from APP_VERSION,
+// then from the main module version, then from the VCS revision with a "-dirty" suffix for modified trees.
+// Instance is taken from APP_INSTANCE, then POD_NAME, then the hostname, falling back to a random ID.
+func ParseAppInfo() (AppInfo, error) {
+	appEnv, err := env.ParseAs[appInfoEnv]()
+	if err != nil {
+		return AppInfo{}, fmt.Errorf("parse app info: %w", err)
+	}
+	info := AppInfo{
+		Service:   appEnv.Service,
+		Namespace: appEnv.Namespace,
+		Version:   appEnv.Version,
+		Instance:  appEnv.Instance,
+	}
+	if info.Version == "" {
+		info.Version = BuildVersion()
+	}
+	if info.Instance == "" {
+		info.Instance = appEnv.PodName
+	}
+	if info.Instance == "" {
+		if host, err := hostname(); err == nil {
+			info.Instance = host
+		}
+	}
+	if info.Instance == "" {
+		info.Instance = nanoid.RandomID()
+	}
+	return info, nil
+}
+
+// BuildVersion reports the version of the running binary derived from debug.ReadBuildInfo.
+func BuildVersion() string {
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return appVersionUnknown
+	}
+	return versionFromBuildInfo(buildInfo)
+}
+
+func versionFromBuildInfo(buildInfo *debug.BuildInfo) string {
+	if v := buildInfo.Main.Version; v != "" && v != appVersionDevel {
+		return v
+	}
+	var revision string
+	var modified bool
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return appVersionUnknown
+	}
+	if len(revision) > appRevisionShortLen {
+		revision = revision[:appRevisionShortLen]
+	}
+	if modified {
+		revision += appVersionDirty
+	}
+	return revision
+}
@@ -0,0 +1,129 @@
+package config_test
+
+import (
+	"errors"
+	"runtime/debug"
+	"testing"
+
+	"github.com/IndexStorm/common-go/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAppInfo_Instance(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		hostname func() (string, error)
+		want     string
+	}{
+		{
+			name:     "app instance wins",
+			env:      map[string]string{"APP_INSTANCE": "instance-1", "POD_NAME": "pod-1"},
+			hostname: func() (string, error) { return "host-1", nil },
+			want:     "instance-1",
+		},
+		{
+			name:     "pod name",
+			env:      map[string]string{"POD_NAME": "pod-1"},
+			hostname: func() (string, error) { return "host-1", nil },
+			want:     "pod-1",
+		},
+		{
+			name:     "hostname",
+			hostname: func() (string, error) { return "host-1", nil },
+			want:     "host-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_SERVICE", "billing")
+			t.Setenv("APP_INSTANCE", "")
+			t.Setenv("POD_NAME", "")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			config.SetHostname(t, tt.hostname)
+
+			info, err := config.ParseAppInfo()
+			require.NoError(t, err)
+			require.Equal(t, "billing", info.Service)
+			require.Equal(t, tt.want, info.Instance)
+		})
+	}
+}
+
+func TestParseAppInfo_RandomInstance(t *testing.T) {
+	t.Setenv("APP_SERVICE", "billing")
+	t.Setenv("APP_INSTANCE", "")
+	t.Setenv("POD_NAME", "")
+	config.SetHostname(t, func() (string, error) { return "", errors.New("no hostname") })
+
+	first, err := config.ParseAppInfo()
+	require.NoError(t, err)
+	second, err := config.ParseAppInfo()
+	require.NoError(t, err)
+	require.NotEmpty(t, first.Instance)
+	require.NotEqual(t, first.Instance, second.Instance)
+}
+
+func TestParseAppInfo_ServiceRequired(t *testing.T) {
+	t.Setenv("APP_SERVICE", "")
+
+	_, err := config.ParseAppInfo()
+	require.ErrorContains(t, err, "APP_SERVICE")
+}
+
+func TestParseAppInfo_VersionFromEnv(t *testing.T) {
+	t.Setenv("APP_SERVICE", "billing")
+	t.Setenv("APP_VERSION", "v1.2.3")
+
+	info, err := config.ParseAppInfo()
+	require.NoError(t, err)
+	require.Equal(t, "v1.2.3", info.Version)
+}
+
+func TestVersionFromBuildInfo(t *testing.T) {
+	const revision = "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		name string
+		info debug.BuildInfo
+		want string
+	}{
+		{
+			name: "module version",
+			info: debug.BuildInfo{Main: debug.Module{Version: "v1.4.0"}},
+			want: "v1.4.0",
+		},
+		{
+			name: "clean revision",
+			info: debug.BuildInfo{
+				Main:     debug.Module{Version: "(devel)"},
+				Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: revision}, {Key: "vcs.modified", Value: "false"}},
+			},
+			want: "0123456789ab",
+		},
+		{
+			name: "dirty revision",
+			info: debug.BuildInfo{
+				Main:     debug.Module{Version: "(devel)"},
+				Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: revision}, {Key: "vcs.modified", Value: "true"}},
+			},
+			want: "0123456789ab-dirty",
+		},
+		{
+			name: "short revision",
+			info: debug.BuildInfo{Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "abc"}}},
+			want: "abc",
+		},
+		{
+			name: "unknown",
+			info: debug.BuildInfo{Main: debug.Module{Version: "(devel)"}},
+			want: "unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, config.VersionFromBuildInfo(&tt.info))
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package config
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func VersionFromBuildInfo(buildInfo *debug.BuildInfo) string {
+	return versionFromBuildInfo(buildInfo)
+}
+
+func SetHostname(t testing.TB, fn func() (string, error)) {
+	previous := hostname
+	hostname = fn
+	t.Cleanup(func() {
+		hostname = previous
+	})
+}
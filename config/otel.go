@@ -1,7 +1,12 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"github.com/caarlos0/env/v11"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -12,32 +17,182 @@ const (
 	OpenTelemetryMethodGRPC OpenTelemetryMethod = "GRPC"
 )
 
+const (
+	otelTracePrefix = "OTEL_TRACE_"
+	otelMeterPrefix = "OTEL_METER_"
+)
+
+var (
+	ErrInvalidOpenTelemetryMethod   = errors.New("invalid open telemetry method")
+	ErrInvalidOpenTelemetryEndpoint = errors.New("invalid open telemetry endpoint")
+	ErrInvalidOpenTelemetryHeaders  = errors.New("invalid open telemetry headers")
+	ErrInvalidOpenTelemetryMode     = errors.New("invalid open telemetry config mode")
+)
+
+// OpenTelemetryMode selects which environment variables ParseOpenTelemetry reads.
+//
+//   - OpenTelemetryModeCustom reads only OTEL_TRACE_* and OTEL_METER_*.
+//   - OpenTelemetryModeStandard reads only the spec-standard OTEL_EXPORTER_OTLP_* family.
+//   - OpenTelemetryModeAuto reads both. For each signal a configured OTEL_TRACE_* or
+//     OTEL_METER_* set wins as a whole, and the standard variables are used for
+//     signals the custom ones leave unconfigured.
+//
+// The mode is taken from OTEL_CONFIG_MODE and defaults to OpenTelemetryModeCustom.
+type OpenTelemetryMode string
+
+const (
+	OpenTelemetryModeCustom   OpenTelemetryMode = "CUSTOM"
+	OpenTelemetryModeStandard OpenTelemetryMode = "STANDARD"
+	OpenTelemetryModeAuto     OpenTelemetryMode = "AUTO"
+)
+
+func (m *OpenTelemetryMode) UnmarshalText(text []byte) error {
+	mode := OpenTelemetryMode(strings.ToUpper(strings.TrimSpace(string(text))))
+	switch mode {
+	case OpenTelemetryModeCustom, OpenTelemetryModeStandard, OpenTelemetryModeAuto:
+		*m = mode
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidOpenTelemetryMode, string(text))
+	}
+}
+
+// UnmarshalText parses the method case-insensitively, so both "http" and "HTTP" are accepted.
+func (m *OpenTelemetryMethod) UnmarshalText(text []byte) error {
+	method := OpenTelemetryMethod(strings.ToUpper(strings.TrimSpace(string(text))))
+	switch method {
+	case OpenTelemetryMethodHTTP, OpenTelemetryMethodGRPC:
+		*m = method
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidOpenTelemetryMethod, string(text))
+	}
+}
+
+// OpenTelemetryHeaders holds exporter headers in the W3C baggage-like format
+// used by OTEL_EXPORTER_OTLP_HEADERS: "key1=value1,key2=value2" with URL-encoded values.
+type OpenTelemetryHeaders map[string]string
+
+func (h *OpenTelemetryHeaders) UnmarshalText(text []byte) error {
+	headers := make(OpenTelemetryHeaders)
+	for _, pair := range strings.Split(string(text), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		rawKey, rawValue, found := strings.Cut(pair, "=")
+		if !found {
+			return fmt.Errorf("%w: missing '=' in pair", ErrInvalidOpenTelemetryHeaders)
+		}
+		key, err := url.PathUnescape(strings.TrimSpace(rawKey))
+		if err != nil || key == "" {
+			return fmt.Errorf("%w: malformed key", ErrInvalidOpenTelemetryHeaders)
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(rawValue))
+		if err != nil {
+			return fmt.Errorf("%w: malformed value of %q", ErrInvalidOpenTelemetryHeaders, key)
+		}
+		headers[key] = value
+	}
+	*h = headers
+	return nil
+}
+
 type OpenTelemetry struct {
 	Trace *TraceConfig `envPrefix:"TRACE_"`
 	Meter *MeterConfig `envPrefix:"METER_"`
 }
 
 type TraceConfig struct {
-	Endpoint      string              `env:"ENDPOINT,notEmpty,unset"`
-	Method        OpenTelemetryMethod `env:"METHOD,notEmpty"`
-	Insecure      bool                `env:"INSECURE"`
-	Authorization string              `env:"AUTHORIZATION,unset"`
+	Endpoint      string               `env:"ENDPOINT,notEmpty,unset"`
+	Method        OpenTelemetryMethod  `env:"METHOD,notEmpty"`
+	Insecure      bool                 `env:"INSECURE"`
+	Authorization string               `env:"AUTHORIZATION,unset"`
+	Headers       OpenTelemetryHeaders `env:"HEADERS,unset"`
+	Sampler       string               `env:"SAMPLER"`
+	SamplerArg    string               `env:"SAMPLER_ARG"`
 }
 
 type MeterConfig struct {
-	Endpoint      string              `env:"ENDPOINT,notEmpty,unset"`
-	Method        OpenTelemetryMethod `env:"METHOD,notEmpty"`
-	Insecure      bool                `env:"INSECURE"`
-	Authorization string              `env:"AUTHORIZATION,unset"`
-	Interval      time.Duration       `env:"INTERVAL" envDefault:"1m"`
+	Endpoint      string               `env:"ENDPOINT,notEmpty,unset"`
+	Method        OpenTelemetryMethod  `env:"METHOD,notEmpty"`
+	Insecure      bool                 `env:"INSECURE"`
+	Authorization string               `env:"AUTHORIZATION,unset"`
+	Headers       OpenTelemetryHeaders `env:"HEADERS,unset"`
+	Interval      time.Duration        `env:"INTERVAL" envDefault:"1m"`
+}
+
+type openTelemetryModeConfig struct {
+	Mode OpenTelemetryMode `env:"OTEL_CONFIG_MODE" envDefault:"CUSTOM"`
+}
+
+// ParseOpenTelemetry reads the exporter configuration in the mode selected by OTEL_CONFIG_MODE.
+// It returns nil without an error when no signal is configured, and an error
+// when a signal is configured only partially or with invalid values.
+func ParseOpenTelemetry() (*OpenTelemetry, error) {
+	modeCfg, err := env.ParseAs[openTelemetryModeConfig]()
+	if err != nil {
+		return nil, fmt.Errorf("parse config mode: %w", err)
+	}
+	return ParseOpenTelemetryWithMode(modeCfg.Mode)
+}
+
+func ParseOpenTelemetryWithMode(mode OpenTelemetryMode) (*OpenTelemetry, error) {
+	switch mode {
+	case OpenTelemetryModeCustom:
+		return parseCustomOpenTelemetry()
+	case OpenTelemetryModeStandard:
+		return parseStandardOpenTelemetry()
+	case OpenTelemetryModeAuto:
+		custom, err := parseCustomOpenTelemetry()
+		if err != nil {
+			return nil, err
+		}
+		standard, err := parseStandardOpenTelemetry()
+		if err != nil {
+			return nil, err
+		}
+		return mergeOpenTelemetry(custom, standard), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidOpenTelemetryMode, mode)
+	}
 }
 
+func parseCustomOpenTelemetry() (*OpenTelemetry, error) {
+	var telemetryCfg OpenTelemetry
+	if isEnvPrefixSet(otelTracePrefix) {
+		traceCfg, err := env.ParseAsWithOptions[TraceConfig](env.Options{Prefix: otelTracePrefix})
+		if err != nil {
+			return nil, fmt.Errorf("parse trace config: %w", err)
+		}
+		if err = validateOtelEndpoint(traceCfg.Endpoint); err != nil {
+			return nil, fmt.Errorf("parse trace config: %w", err)
+		}
+		telemetryCfg.Trace = &traceCfg
+	}
+	if isEnvPrefixSet(otelMeterPrefix) {
+		meterCfg, err := env.ParseAsWithOptions[MeterConfig](env.Options{Prefix: otelMeterPrefix})
+		if err != nil {
+			return nil, fmt.Errorf("parse meter config: %w", err)
+		}
+		if err = validateOtelEndpoint(meterCfg.Endpoint); err != nil {
+			return nil, fmt.Errorf("parse meter config: %w", err)
+		}
+		telemetryCfg.Meter = &meterCfg
+	}
+	if telemetryCfg.Trace != nil || telemetryCfg.Meter != nil {
+		return &telemetryCfg, nil
+	}
+	return nil, nil
+}
+
+// Deprecated: TryParseOpenTelemetry silently drops invalid configuration, use ParseOpenTelemetry instead.
 func TryParseOpenTelemetry() *OpenTelemetry {
 	var telemetryCfg OpenTelemetry
-	if traceCfg, err := env.ParseAsWithOptions[TraceConfig](env.Options{Prefix: "OTEL_TRACE_"}); err == nil {
+	if traceCfg, err := env.ParseAsWithOptions[TraceConfig](env.Options{Prefix: otelTracePrefix}); err == nil {
 		telemetryCfg.Trace = &traceCfg
 	}
-	if meterCfg, err := env.ParseAsWithOptions[MeterConfig](env.Options{Prefix: "OTEL_METER_"}); err == nil {
+	if meterCfg, err := env.ParseAsWithOptions[MeterConfig](env.Options{Prefix: otelMeterPrefix}); err == nil {
 		telemetryCfg.Meter = &meterCfg
 	}
 	if telemetryCfg.Trace != nil || telemetryCfg.Meter != nil {
@@ -45,3 +200,26 @@ func TryParseOpenTelemetry() *OpenTelemetry {
 	}
 	return nil
 }
+
+func isEnvPrefixSet(prefix string) bool {
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateOtelEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("%w: malformed url", ErrInvalidOpenTelemetryEndpoint)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: unsupported scheme %q", ErrInvalidOpenTelemetryEndpoint, u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%w: missing host", ErrInvalidOpenTelemetryEndpoint)
+	}
+	return nil
+}
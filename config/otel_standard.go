@@ -0,0 +1,176 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	otlpSignalTraces  = "TRACES"
+	otlpSignalMetrics = "METRICS"
+)
+
+const (
+	otlpProtocolGRPC         = "grpc"
+	otlpProtocolHTTPProtobuf = "http/protobuf"
+)
+
+// parseStandardOpenTelemetry reads the variables defined by the OpenTelemetry specification.
+// Signal-specific variables (e.g. OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) take precedence over
+// the generic ones (OTEL_EXPORTER_OTLP_ENDPOINT), headers are merged key by key.
+func parseStandardOpenTelemetry() (*OpenTelemetry, error) {
+	if disabled, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); disabled {
+		return nil, nil
+	}
+	var telemetryCfg OpenTelemetry
+	tracesEnabled, err := otlpExporterEnabled("OTEL_TRACES_EXPORTER")
+	if err != nil {
+		return nil, fmt.Errorf("parse standard trace config: %w", err)
+	}
+	if tracesEnabled && otlpEndpointSet(otlpSignalTraces) {
+		exporter, err := parseOtlpExporter(otlpSignalTraces, "/v1/traces")
+		if err != nil {
+			return nil, fmt.Errorf("parse standard trace config: %w", err)
+		}
+		telemetryCfg.Trace = &TraceConfig{
+			Endpoint: exporter.endpoint,
+			Method:   exporter.method,
+			Insecure: exporter.insecure,
+			Headers:  exporter.headers,
+		}
+		telemetryCfg.Trace.Sampler, telemetryCfg.Trace.SamplerArg = standardTraceSampler()
+	}
+	metricsEnabled, err := otlpExporterEnabled("OTEL_METRICS_EXPORTER")
+	if err != nil {
+		return nil, fmt.Errorf("parse standard meter config: %w", err)
+	}
+	if metricsEnabled && otlpEndpointSet(otlpSignalMetrics) {
+		exporter, err := parseOtlpExporter(otlpSignalMetrics, "/v1/metrics")
+		if err != nil {
+			return nil, fmt.Errorf("parse standard meter config: %w", err)
+		}
+		interval := time.Minute
+		if raw := os.Getenv("OTEL_METRIC_EXPORT_INTERVAL"); raw != "" {
+			millis, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || millis <= 0 {
+				return nil, fmt.Errorf("parse standard meter config: invalid OTEL_METRIC_EXPORT_INTERVAL %q", raw)
+			}
+			interval = time.Duration(millis) * time.Millisecond
+		}
+		telemetryCfg.Meter = &MeterConfig{
+			Endpoint: exporter.endpoint,
+			Method:   exporter.method,
+			Insecure: exporter.insecure,
+			Headers:  exporter.headers,
+			Interval: interval,
+		}
+	}
+	if telemetryCfg.Trace != nil || telemetryCfg.Meter != nil {
+		return &telemetryCfg, nil
+	}
+	return nil, nil
+}
+
+// mergeOpenTelemetry prefers the custom configuration of each signal over the standard one.
+// The standard sampler is still honoured when the custom trace config does not set its own.
+func mergeOpenTelemetry(custom, standard *OpenTelemetry) *OpenTelemetry {
+	if custom == nil {
+		return standard
+	}
+	if standard == nil {
+		standard = &OpenTelemetry{}
+	}
+	merged := OpenTelemetry{Trace: custom.Trace, Meter: custom.Meter}
+	if merged.Trace == nil {
+		merged.Trace = standard.Trace
+	} else if merged.Trace.Sampler == "" {
+		merged.Trace.Sampler, merged.Trace.SamplerArg = standardTraceSampler()
+	}
+	if merged.Meter == nil {
+		merged.Meter = standard.Meter
+	}
+	return &merged
+}
+
+type otlpExporter struct {
+	endpoint string
+	method   OpenTelemetryMethod
+	insecure bool
+	headers  OpenTelemetryHeaders
+}
+
+func parseOtlpExporter(signal string, httpPath string) (*otlpExporter, error) {
+	var exporter otlpExporter
+	protocol := otlpEnv(signal, "PROTOCOL")
+	if protocol == "" {
+		protocol = otlpProtocolHTTPProtobuf
+	}
+	switch strings.ToLower(protocol) {
+	case otlpProtocolGRPC:
+		exporter.method = OpenTelemetryMethodGRPC
+	case otlpProtocolHTTPProtobuf:
+		exporter.method = OpenTelemetryMethodHTTP
+	default:
+		return nil, fmt.Errorf("%w: unsupported protocol %q", ErrInvalidOpenTelemetryMethod, protocol)
+	}
+	exporter.endpoint = os.Getenv("OTEL_EXPORTER_OTLP_" + signal + "_ENDPOINT")
+	if exporter.endpoint == "" {
+		exporter.endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		// Per the specification only the generic endpoint gets the signal path appended for HTTP.
+		if exporter.method == OpenTelemetryMethodHTTP {
+			exporter.endpoint = strings.TrimSuffix(exporter.endpoint, "/") + httpPath
+		}
+	}
+	if err := validateOtelEndpoint(exporter.endpoint); err != nil {
+		return nil, err
+	}
+	if raw := otlpEnv(signal, "INSECURE"); raw != "" {
+		insecure, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid insecure flag %q: %w", raw, err)
+		}
+		exporter.insecure = insecure
+	}
+	exporter.headers = make(OpenTelemetryHeaders)
+	for _, key := range []string{"OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_" + signal + "_HEADERS"} {
+		var headers OpenTelemetryHeaders
+		if err := headers.UnmarshalText([]byte(os.Getenv(key))); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", key, err)
+		}
+		for k, v := range headers {
+			exporter.headers[k] = v
+		}
+	}
+	return &exporter, nil
+}
+
+// otlpEnv returns the signal-specific variable if set, otherwise the generic one.
+func otlpEnv(signal string, name string) string {
+	if value := os.Getenv("OTEL_EXPORTER_OTLP_" + signal + "_" + name); value != "" {
+		return value
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_" + name)
+}
+
+func otlpEndpointSet(signal string) bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_"+signal+"_ENDPOINT") != ""
+}
+
+func otlpExporterEnabled(key string) (bool, error) {
+	switch value := strings.ToLower(strings.TrimSpace(os.Getenv(key))); value {
+	case "", "otlp":
+		return true, nil
+	case "none":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unsupported %s %q", key, value)
+	}
+}
+
+func standardTraceSampler() (string, string) {
+	return os.Getenv("OTEL_TRACES_SAMPLER"), os.Getenv("OTEL_TRACES_SAMPLER_ARG")
+}
@@ -0,0 +1,104 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IndexStorm/common-go/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOpenTelemetry_NotConfigured(t *testing.T) {
+	cfg, err := config.ParseOpenTelemetry()
+	require.NoError(t, err)
+	require.Nil(t, cfg)
+}
+
+func TestParseOpenTelemetry_CaseInsensitiveMethod(t *testing.T) {
+	t.Setenv("OTEL_TRACE_ENDPOINT", "https://collector:4318/v1/traces")
+	t.Setenv("OTEL_TRACE_METHOD", "http")
+
+	cfg, err := config.ParseOpenTelemetry()
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+	require.NotNil(t, cfg.Trace)
+	require.Nil(t, cfg.Meter)
+	require.Equal(t, config.OpenTelemetryMethodHTTP, cfg.Trace.Method)
+}
+
+func TestParseOpenTelemetry_InvalidMethod(t *testing.T) {
+	t.Setenv("OTEL_TRACE_ENDPOINT", "https://collector:4318/v1/traces")
+	t.Setenv("OTEL_TRACE_METHOD", "udp")
+
+	_, err := config.ParseOpenTelemetry()
+	require.ErrorContains(t, err, config.ErrInvalidOpenTelemetryMethod.Error())
+}
+
+func TestParseOpenTelemetry_PartiallyConfigured(t *testing.T) {
+	t.Setenv("OTEL_METER_METHOD", "GRPC")
+
+	_, err := config.ParseOpenTelemetry()
+	require.Error(t, err)
+}
+
+func TestParseOpenTelemetry_InvalidEndpoint(t *testing.T) {
+	t.Setenv("OTEL_METER_ENDPOINT", "collector:4317")
+	t.Setenv("OTEL_METER_METHOD", "grpc")
+
+	_, err := config.ParseOpenTelemetry()
+	require.ErrorIs(t, err, config.ErrInvalidOpenTelemetryEndpoint)
+}
+
+func TestParseOpenTelemetry_StandardMode(t *testing.T) {
+	t.Setenv("OTEL_CONFIG_MODE", "standard")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318/")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Basic%20abc, x-tenant = acme")
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_HEADERS", "x-tenant=metrics")
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_PROTOCOL", "grpc")
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "http://collector:4317")
+	t.Setenv("OTEL_TRACES_SAMPLER", "parentbased_traceidratio")
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "0.25")
+	t.Setenv("OTEL_METRIC_EXPORT_INTERVAL", "15000")
+
+	cfg, err := config.ParseOpenTelemetry()
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+
+	require.NotNil(t, cfg.Trace)
+	require.Equal(t, "http://collector:4318/v1/traces", cfg.Trace.Endpoint)
+	require.Equal(t, config.OpenTelemetryMethodHTTP, cfg.Trace.Method)
+	require.Equal(t, config.OpenTelemetryHeaders{"Authorization": "Basic abc", "x-tenant": "acme"}, cfg.Trace.Headers)
+	require.Equal(t, "parentbased_traceidratio", cfg.Trace.Sampler)
+	require.Equal(t, "0.25", cfg.Trace.SamplerArg)
+
+	require.NotNil(t, cfg.Meter)
+	require.Equal(t, "http://collector:4317", cfg.Meter.Endpoint)
+	require.Equal(t, config.OpenTelemetryMethodGRPC, cfg.Meter.Method)
+	require.Equal(t, config.OpenTelemetryHeaders{"Authorization": "Basic abc", "x-tenant": "metrics"}, cfg.Meter.Headers)
+	require.Equal(t, 15*time.Second, cfg.Meter.Interval)
+}
+
+func TestParseOpenTelemetry_AutoModePrefersCustom(t *testing.T) {
+	t.Setenv("OTEL_CONFIG_MODE", "AUTO")
+	t.Setenv("OTEL_TRACE_ENDPOINT", "https://custom:4318/v1/traces")
+	t.Setenv("OTEL_TRACE_METHOD", "HTTP")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://standard:4318")
+	t.Setenv("OTEL_TRACES_SAMPLER", "always_off")
+
+	cfg, err := config.ParseOpenTelemetry()
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+	require.Equal(t, "https://custom:4318/v1/traces", cfg.Trace.Endpoint)
+	require.Equal(t, "always_off", cfg.Trace.Sampler)
+	require.NotNil(t, cfg.Meter)
+	require.Equal(t, "http://standard:4318/v1/metrics", cfg.Meter.Endpoint)
+}
+
+func TestParseOpenTelemetry_StandardModeUnsupportedProtocol(t *testing.T) {
+	t.Setenv("OTEL_CONFIG_MODE", "STANDARD")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/json")
+
+	_, err := config.ParseOpenTelemetry()
+	require.ErrorIs(t, err, config.ErrInvalidOpenTelemetryMethod)
+}
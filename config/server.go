@@ -1,5 +1,16 @@
 package config
 
+import "time"
+
 type Server struct {
-	ListenAddress string `env:"LISTEN_ADDRESS,notEmpty"`
+	ListenAddress     string        `env:"LISTEN_ADDRESS,notEmpty"`
+	ReadTimeout       time.Duration `env:"READ_TIMEOUT" envDefault:"30s"`
+	ReadHeaderTimeout time.Duration `env:"READ_HEADER_TIMEOUT" envDefault:"10s"`
+	WriteTimeout      time.Duration `env:"WRITE_TIMEOUT" envDefault:"30s"`
+	IdleTimeout       time.Duration `env:"IDLE_TIMEOUT" envDefault:"2m"`
+	MaxHeaderBytes    int           `env:"MAX_HEADER_BYTES" envDefault:"1048576"`
+	TLSCertFile       string        `env:"TLS_CERT_FILE"`
+	TLSKeyFile        string        `env:"TLS_KEY_FILE"`
+	TLSReloadInterval time.Duration `env:"TLS_RELOAD_INTERVAL" envDefault:"1m"`
+	H2C               bool          `env:"H2C"`
 }
@@ -0,0 +1,206 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	advisoryLockPollMin = time.Millisecond * 50
+	advisoryLockPollMax = time.Second
+)
+
+var (
+	ErrLockTimeout         = errors.New("pgx: advisory lock timeout")
+	ErrAcquireNotSupported = errors.New("pgx: pool wrapper does not support pinning connections")
+)
+
+// ConnAcquirer is implemented by pool wrappers able to pin a connection, session locks need it.
+type ConnAcquirer interface {
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+}
+
+// AdvisoryLockKey hashes a lock name into the bigint key space of Postgres advisory locks.
+func AdvisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// SessionLock is an advisory lock held by a connection pinned from the pool until Release.
+type SessionLock struct {
+	conn    *pgxpool.Conn
+	key     int64
+	once    sync.Once
+	stop    func() bool
+	release error
+}
+
+// AcquireSessionLock blocks until the lock is granted. The lock is released automatically once ctx is done.
+func AcquireSessionLock(ctx context.Context, pool PgxPoolWrapper, name string) (*SessionLock, error) {
+	key := AdvisoryLockKey(name)
+	conn, err := acquireConn(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		// The lock may still be granted after an interrupted wait, only closing the session drops it for sure
+		_ = conn.Hijack().Close(context.Background())
+		return nil, fmt.Errorf("advisory lock %s: %w", name, err)
+	}
+	return newSessionLock(ctx, conn, key), nil
+}
+
+// TryAcquireSessionLock returns nil without an error when the lock is held by someone else.
+func TryAcquireSessionLock(ctx context.Context, pool PgxPoolWrapper, name string) (*SessionLock, error) {
+	key := AdvisoryLockKey(name)
+	conn, err := acquireConn(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	var locked bool
+	if err = conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		_ = conn.Hijack().Close(context.Background())
+		return nil, fmt.Errorf("try advisory lock %s: %w", name, err)
+	}
+	if !locked {
+		conn.Release()
+		return nil, nil
+	}
+	return newSessionLock(ctx, conn, key), nil
+}
+
+// AcquireSessionLockWithTimeout polls for the lock and returns ErrLockTimeout if it is not granted within timeout.
+func AcquireSessionLockWithTimeout(
+	ctx context.Context, pool PgxPoolWrapper, name string, timeout time.Duration,
+) (*SessionLock, error) {
+	var lock *SessionLock
+	err := pollAdvisoryLock(ctx, timeout, func() (bool, error) {
+		var err error
+		lock, err = TryAcquireSessionLock(ctx, pool, name)
+		return lock != nil, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("advisory lock %s: %w", name, err)
+	}
+	return lock, nil
+}
+
+func acquireConn(ctx context.Context, pool PgxPoolWrapper) (*pgxpool.Conn, error) {
+	acquirer, ok := pool.(ConnAcquirer)
+	if !ok {
+		return nil, ErrAcquireNotSupported
+	}
+	conn, err := acquirer.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire connection: %w", err)
+	}
+	return conn, nil
+}
+
+func newSessionLock(ctx context.Context, conn *pgxpool.Conn, key int64) *SessionLock {
+	lock := &SessionLock{conn: conn, key: key}
+	lock.stop = context.AfterFunc(ctx, func() {
+		_ = lock.Release(context.Background())
+	})
+	return lock
+}
+
+func (l *SessionLock) Key() int64 {
+	return l.key
+}
+
+// Ping checks that the pinned connection is alive, the lock is lost together with the session.
+func (l *SessionLock) Ping(ctx context.Context) error {
+	return l.conn.Ping(ctx)
+}
+
+// Release unlocks and returns the connection to the pool. It is safe to call multiple times.
+func (l *SessionLock) Release(ctx context.Context) error {
+	l.once.Do(func() {
+		l.stop()
+		if _, err := l.conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", l.key); err != nil {
+			// Closing the session releases every lock it holds
+			l.release = errors.Join(fmt.Errorf("advisory unlock: %w", err), l.conn.Hijack().Close(context.Background()))
+			return
+		}
+		l.conn.Release()
+	})
+	return l.release
+}
+
+// AcquireTxLock blocks until the lock is granted, it is released when the transaction carried by ctx ends.
+func AcquireTxLock(ctx context.Context, pool PgxPoolWrapper, name string) error {
+	if _, ok := ctx.Value(PgxConnectionCtxKey{}).(pgx.Tx); !ok {
+		return ErrNotInTx
+	}
+	if _, err := pool.GetConnectionFromCtx(ctx).Exec(ctx, "SELECT pg_advisory_xact_lock($1)", AdvisoryLockKey(name)); err != nil {
+		return fmt.Errorf("advisory xact lock %s: %w", name, err)
+	}
+	return nil
+}
+
+// TryAcquireTxLock reports whether the lock was granted, it is released when the transaction carried by ctx ends.
+func TryAcquireTxLock(ctx context.Context, pool PgxPoolWrapper, name string) (bool, error) {
+	if _, ok := ctx.Value(PgxConnectionCtxKey{}).(pgx.Tx); !ok {
+		return false, ErrNotInTx
+	}
+	var locked bool
+	err := pool.GetConnectionFromCtx(ctx).
+		QueryRow(ctx, "SELECT pg_try_advisory_xact_lock($1)", AdvisoryLockKey(name)).
+		Scan(&locked)
+	if err != nil {
+		return false, fmt.Errorf("try advisory xact lock %s: %w", name, err)
+	}
+	return locked, nil
+}
+
+// AcquireTxLockWithTimeout polls for the lock and returns ErrLockTimeout if it is not granted within timeout.
+func AcquireTxLockWithTimeout(ctx context.Context, pool PgxPoolWrapper, name string, timeout time.Duration) error {
+	return pollAdvisoryLock(ctx, timeout, func() (bool, error) {
+		return TryAcquireTxLock(ctx, pool, name)
+	})
+}
+
+// RunExclusive runs fn only if the named session lock is free, which gives cron-like jobs
+// "exactly one replica" semantics. It reports whether fn ran.
+func RunExclusive(ctx context.Context, pool PgxPoolWrapper, name string, fn func(ctx context.Context) error) (bool, error) {
+	lock, err := TryAcquireSessionLock(ctx, pool, name)
+	if err != nil || lock == nil {
+		return false, err
+	}
+	defer lock.Release(context.Background())
+	return true, fn(ctx)
+}
+
+func pollAdvisoryLock(ctx context.Context, timeout time.Duration, try func() (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	interval := advisoryLockPollMin
+	for {
+		locked, err := try()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+				return ErrLockTimeout
+			}
+			return err
+		}
+		if locked {
+			return nil
+		}
+		if err = sleepContext(ctx, interval); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return ErrLockTimeout
+			}
+			return err
+		}
+		interval = min(interval*2, advisoryLockPollMax)
+	}
+}
@@ -0,0 +1,36 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionLock_ReleasedWhenContextIsDone(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lock, err := db.TryAcquireSessionLock(ctx, wrapper, "reindex")
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+
+	other, err := db.TryAcquireSessionLock(context.Background(), wrapper, "reindex")
+	require.NoError(t, err)
+	require.Nil(t, other)
+	_, err = db.AcquireSessionLockWithTimeout(context.Background(), wrapper, "reindex", time.Millisecond*100)
+	require.ErrorIs(t, err, db.ErrLockTimeout)
+
+	cancel()
+	require.Eventually(t, func() bool {
+		other, err = db.TryAcquireSessionLock(context.Background(), wrapper, "reindex")
+		require.NoError(t, err)
+		return other != nil
+	}, time.Second*5, time.Millisecond*10)
+	require.NoError(t, other.Release(context.Background()))
+	require.NoError(t, lock.Release(context.Background()))
+}
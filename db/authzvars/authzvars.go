@@ -0,0 +1,31 @@
+// Package authzvars exposes the authz principal of a request to row-level security policies,
+// keeping the authz package free of a database dependency.
+package authzvars
+
+import (
+	"context"
+	"strings"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/service/authz"
+)
+
+// Principal exposes the principal to row-level security policies as app.user_id, app.tenant_id
+// and app.roles, the roles being comma separated. Use them with db.NewRowLevelSecurityWrapper.
+func Principal() []db.SessionVariable {
+	variable := func(name string, value func(authz.Principal) string) db.SessionVariable {
+		return db.SessionVariable{Name: name, Value: func(ctx context.Context) (string, bool) {
+			principal, ok := authz.PrincipalFromContext(ctx)
+			if !ok {
+				return "", false
+			}
+			v := value(principal)
+			return v, v != ""
+		}}
+	}
+	return []db.SessionVariable{
+		variable("app.user_id", func(p authz.Principal) string { return p.UserID }),
+		variable("app.tenant_id", func(p authz.Principal) string { return p.TenantID }),
+		variable("app.roles", func(p authz.Principal) string { return strings.Join(p.Roles, ",") }),
+	}
+}
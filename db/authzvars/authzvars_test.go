@@ -0,0 +1,33 @@
+package authzvars_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/authzvars"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/IndexStorm/common-go/service/authz"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrincipal(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	pool := db.NewRowLevelSecurityWrapper(fake, db.RowLevelSecurityConfig{Variables: authzvars.Principal()})
+	ctx := authz.ContextWithPrincipal(context.Background(), authz.Principal{
+		UserID: "u1",
+		Roles:  []string{"admin", "billing"},
+	})
+
+	// Empty fields of the principal are left unset
+	fake.Expect(dbtest.Exact("SELECT set_config($1, $2, true), set_config($3, $4, true)")).
+		WithArgs("app.roles", "admin,billing", "app.user_id", "u1")
+	require.NoError(t, pool.RunInTx(ctx, func(context.Context) error {
+		return nil
+	}))
+
+	require.NoError(t, pool.RunInTx(context.Background(), func(context.Context) error {
+		return nil
+	}))
+	require.Equal(t, 2, fake.Commits())
+}
@@ -0,0 +1,229 @@
+// Package dbtest provides integration tests with a fresh Postgres database each.
+//
+// The server is configured with the TEST_DB_HOST, TEST_DB_USERNAME, TEST_DB_PASSWORD, TEST_DB_DATABASE
+// and TEST_DB_SSL_MODE variables, TEST_DB_DATABASE naming an existing database used for administration.
+// TEST_DB_PASSWORD may be empty for servers using trust authentication.
+// Tests calling New are skipped when TEST_DB_HOST is not set.
+//
+// Migrated schemas are kept on the server as dbtest_template_* databases, so later runs skip the migrations.
+// A template is dropped when the migrations of its schema dir change, the remaining ones may be dropped by hand.
+package dbtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IndexStorm/common-go/config"
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/migration"
+	"github.com/caarlos0/env/v11"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+const envPrefix = "TEST_"
+
+var (
+	serverOnce   sync.Once
+	serverSet    bool
+	serverConfig config.Database
+	serverErr    error
+	templates    sync.Map
+	databaseSeq  atomic.Uint64
+)
+
+// serverEnv mirrors config.Database with an optional password.
+type serverEnv struct {
+	Host     string `env:"DB_HOST,notEmpty"`
+	Username string `env:"DB_USERNAME,notEmpty"`
+	Password string `env:"DB_PASSWORD"`
+	Database string `env:"DB_DATABASE,notEmpty"`
+	SSLMode  string `env:"DB_SSL_MODE" envDefault:"require"`
+}
+
+type template struct {
+	once sync.Once
+	name string
+	err  error
+}
+
+// New creates a database from a template migrated with the files of schemaDir and opens a pool to it.
+// The template is built once per test binary and reused across runs while the migrations are unchanged.
+// The database is dropped when the test ends.
+func New(t testing.TB, schemaDir string) (*pgxpool.Pool, db.PgxPoolWrapper) {
+	t.Helper()
+	server, ok := loadServerConfig(t)
+	if !ok {
+		t.Skipf("dbtest: %sDB_HOST is not set, skipping test that needs Postgres", envPrefix)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	templateName, err := prepareTemplate(ctx, server, schemaDir)
+	if err != nil {
+		t.Fatalf("dbtest: prepare template: %v", err)
+	}
+	name := "dbtest_" + strconv.Itoa(os.Getpid()) + "_" + strconv.FormatUint(databaseSeq.Add(1), 10)
+	err = adminExec(ctx, server, "CREATE DATABASE "+pgx.Identifier{name}.Sanitize()+
+		" TEMPLATE "+pgx.Identifier{templateName}.Sanitize())
+	if err != nil {
+		t.Fatalf("dbtest: create database: %v", err)
+	}
+	dbConfig := server
+	dbConfig.Database = name
+	pool, err := db.NewPgxPool(ctx, dbConfig)
+	if err != nil {
+		_ = dropDatabase(server, name)
+		t.Fatalf("dbtest: open pool: %v", err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+		if err := dropDatabase(server, name); err != nil {
+			t.Errorf("dbtest: drop database %s: %v", name, err)
+		}
+	})
+	return pool, db.NewPgxPoolWrapper(pool)
+}
+
+func loadServerConfig(t testing.TB) (config.Database, bool) {
+	serverOnce.Do(func() {
+		if _, serverSet = os.LookupEnv(envPrefix + "DB_HOST"); serverSet {
+			var server serverEnv
+			server, serverErr = env.ParseAsWithOptions[serverEnv](env.Options{Prefix: envPrefix})
+			serverConfig = config.Database(server)
+		}
+	})
+	if serverErr != nil {
+		t.Fatalf("dbtest: parse config: %v", serverErr)
+	}
+	return serverConfig, serverSet
+}
+
+func prepareTemplate(ctx context.Context, server config.Database, schemaDir string) (string, error) {
+	dir, err := filepath.Abs(schemaDir)
+	if err != nil {
+		return "", fmt.Errorf("resolve schema dir: %w", err)
+	}
+	value, _ := templates.LoadOrStore(dir, &template{})
+	tpl := value.(*template)
+	tpl.once.Do(func() {
+		tpl.name, tpl.err = createTemplate(ctx, server, dir)
+	})
+	return tpl.name, tpl.err
+}
+
+// createTemplate migrates a template named after schema dir and the checksum of its migrations. Test binaries
+// run in parallel by go test serialize on an advisory lock and reuse a template built by another one.
+func createTemplate(ctx context.Context, server config.Database, dir string) (string, error) {
+	checksum, err := checksumDir(dir)
+	if err != nil {
+		return "", err
+	}
+	dirSum := sha256.Sum256([]byte(dir))
+	prefix := "dbtest_template_" + hex.EncodeToString(dirSum[:])[:16] + "_"
+	name := prefix + checksum
+	conn, err := pgx.Connect(ctx, db.ConnectionString(server))
+	if err != nil {
+		return "", fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", db.AdvisoryLockKey(name)); err != nil {
+		return "", fmt.Errorf("lock template: %w", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", db.AdvisoryLockKey(name))
+	var exists bool
+	if err = conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", name).Scan(&exists); err != nil {
+		return "", fmt.Errorf("lookup template: %w", err)
+	}
+	if exists {
+		return name, nil
+	}
+	// The template is migrated under a temporary name, so a failed run never leaves a half migrated one
+	building := name + "_building"
+	if _, err = conn.Exec(ctx, "DROP DATABASE IF EXISTS "+pgx.Identifier{building}.Sanitize()+" WITH (FORCE)"); err != nil {
+		return "", fmt.Errorf("drop stale template: %w", err)
+	}
+	if _, err = conn.Exec(ctx, "CREATE DATABASE "+pgx.Identifier{building}.Sanitize()); err != nil {
+		return "", fmt.Errorf("create template: %w", err)
+	}
+	dbConfig := server
+	dbConfig.Database = building
+	// A ForceVersion below -1 runs the up migrations only
+	err = migration.NewPostgresMigrator(zerolog.Nop()).Migrate(ctx, migration.Config{
+		Database:     dbConfig,
+		ForceVersion: -2,
+		SqlSchemaDir: "file://" + dir,
+	})
+	if err != nil {
+		return "", fmt.Errorf("migrate template: %w", err)
+	}
+	_, err = conn.Exec(ctx, "ALTER DATABASE "+pgx.Identifier{building}.Sanitize()+
+		" RENAME TO "+pgx.Identifier{name}.Sanitize())
+	if err != nil {
+		return "", fmt.Errorf("rename template: %w", err)
+	}
+	dropOutdatedTemplates(ctx, conn, prefix, name)
+	return name, nil
+}
+
+// dropOutdatedTemplates drops the templates built from earlier migrations of the same schema dir.
+// A template still being copied by another test binary cannot be dropped, it is left for a later run.
+func dropOutdatedTemplates(ctx context.Context, conn *pgx.Conn, prefix, current string) {
+	rows, err := conn.Query(ctx,
+		"SELECT datname FROM pg_database WHERE starts_with(datname, $1) AND datname <> $2", prefix, current)
+	if err != nil {
+		return
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return
+	}
+	for _, name := range names {
+		_, _ = conn.Exec(ctx, "DROP DATABASE IF EXISTS "+pgx.Identifier{name}.Sanitize())
+	}
+}
+
+func checksumDir(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("read schema dir: %w", err)
+	}
+	h := sha256.New()
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return "", fmt.Errorf("read migration: %w", err)
+		}
+		h.Write([]byte(entry.Name()))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+func dropDatabase(server config.Database, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	return adminExec(ctx, server, "DROP DATABASE IF EXISTS "+pgx.Identifier{name}.Sanitize()+" WITH (FORCE)")
+}
+
+func adminExec(ctx context.Context, server config.Database, sql string) error {
+	conn, err := pgx.Connect(ctx, db.ConnectionString(server))
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+	_, err = conn.Exec(ctx, sql)
+	return err
+}
@@ -0,0 +1,550 @@
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var ErrUnexpectedStatement = errors.New("dbtest: unexpected statement")
+
+// AnyArg matches any argument value in Expectation.WithArgs.
+var AnyArg = anyArg{}
+
+type anyArg struct{}
+
+// SQLMatcher reports whether an executed statement satisfies an expectation.
+type SQLMatcher func(sql string) bool
+
+// Exact matches statements equal to sql, ignoring differences in whitespace.
+func Exact(sql string) SQLMatcher {
+	want := normalizeSQL(sql)
+	return func(sql string) bool {
+		return normalizeSQL(sql) == want
+	}
+}
+
+// Regex matches statements containing a match of pattern.
+func Regex(pattern string) SQLMatcher {
+	re := regexp.MustCompile(pattern)
+	return re.MatchString
+}
+
+// Statement is a statement executed against the fake, transaction control included.
+type Statement struct {
+	SQL  string
+	Args []any
+	// Rows holds the rows sent by CopyFrom.
+	Rows [][]any
+	InTx bool
+}
+
+// Expectation is a scripted response to the next statement matching it.
+type Expectation struct {
+	matcher SQLMatcher
+	args    []any
+	hasArgs bool
+	columns []string
+	rows    [][]any
+	tag     string
+	err     error
+	label   string
+}
+
+// WithArgs restricts the expectation to statements executed with exactly args, AnyArg matches any value.
+func (e *Expectation) WithArgs(args ...any) *Expectation {
+	e.args, e.hasArgs = args, true
+	return e
+}
+
+func (e *Expectation) WillReturnRows(columns []string, rows ...[]any) *Expectation {
+	e.columns, e.rows = columns, rows
+	return e
+}
+
+// WillReturnTag sets the command tag returned by Exec, e.g. "UPDATE 1".
+func (e *Expectation) WillReturnTag(tag string) *Expectation {
+	e.tag = tag
+	return e
+}
+
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+func (e *Expectation) matches(sql string, args []any) bool {
+	if !e.matcher(sql) {
+		return false
+	}
+	if !e.hasArgs {
+		return true
+	}
+	if len(e.args) != len(args) {
+		return false
+	}
+	for i, arg := range e.args {
+		if arg != AnyArg && !reflect.DeepEqual(arg, args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fake is an in-memory db.PgxConnection and db.PgxPoolWrapper. Statements must match the scripted
+// expectations in order, transactions run through the regular wrapper logic and are only recorded.
+// Unexpected statements and expectations left unconsumed at the end of the test fail it.
+type Fake struct {
+	db.PgxPoolWrapper
+	t            testing.TB
+	mu           sync.Mutex
+	expectations []*Expectation
+	statements   []Statement
+	commitErrs   []error
+	savepoints   int
+	commits      int
+	rollbacks    int
+}
+
+func NewFake(t testing.TB) *Fake {
+	f := &Fake{t: t}
+	f.PgxPoolWrapper = db.NewPgxWrapper(f)
+	t.Cleanup(func() {
+		if err := f.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	return f
+}
+
+// Expect scripts the response to the next statement, which must match m.
+func (f *Fake) Expect(m SQLMatcher) *Expectation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e := &Expectation{matcher: m, label: fmt.Sprintf("expectation #%d", len(f.expectations)+1)}
+	f.expectations = append(f.expectations, e)
+	return e
+}
+
+// FailNextCommit makes the next commit of a top level transaction fail with err, the transaction is rolled back.
+func (f *Fake) FailNextCommit(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commitErrs = append(f.commitErrs, err)
+}
+
+func (f *Fake) ExpectationsWereMet() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.expectations) > 0 {
+		return fmt.Errorf("dbtest: %d unconsumed expectations, next is %s", len(f.expectations), f.expectations[0].label)
+	}
+	return nil
+}
+
+// Statements returns everything executed so far, including BEGIN, COMMIT, ROLLBACK and savepoint statements.
+func (f *Fake) Statements() []Statement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Statement(nil), f.statements...)
+}
+
+// Commits returns the number of committed top level transactions.
+func (f *Fake) Commits() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.commits
+}
+
+// Rollbacks returns the number of rolled back top level transactions.
+func (f *Fake) Rollbacks() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rollbacks
+}
+
+func (f *Fake) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return f.exec(sql, args, false)
+}
+
+func (f *Fake) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return f.query(sql, args, false)
+}
+
+func (f *Fake) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return f.queryRow(sql, args, false)
+}
+
+func (f *Fake) CopyFrom(
+	ctx context.Context, table pgx.Identifier, columns []string, rowSrc pgx.CopyFromSource,
+) (int64, error) {
+	return f.copyFrom(table, columns, rowSrc, false)
+}
+
+func (f *Fake) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return &fakeBatchResults{fake: f, queries: b.QueuedQueries}
+}
+
+func (f *Fake) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	f.record(Statement{SQL: "BEGIN", InTx: true})
+	return &fakeTx{fake: f}, nil
+}
+
+func (f *Fake) record(statement Statement) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statements = append(f.statements, statement)
+}
+
+// next records the statement and consumes the expectation answering it.
+func (f *Fake) next(statement Statement) (*Expectation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statements = append(f.statements, statement)
+	if len(f.expectations) == 0 || !f.expectations[0].matches(statement.SQL, statement.Args) {
+		f.t.Errorf("dbtest: unexpected statement %q with args %v", statement.SQL, statement.Args)
+		return nil, fmt.Errorf("%w: %s", ErrUnexpectedStatement, statement.SQL)
+	}
+	e := f.expectations[0]
+	f.expectations = f.expectations[1:]
+	return e, e.err
+}
+
+func (f *Fake) exec(sql string, args []any, inTx bool) (pgconn.CommandTag, error) {
+	e, err := f.next(Statement{SQL: sql, Args: args, InTx: inTx})
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	if e.tag == "" && e.columns != nil {
+		return pgconn.NewCommandTag("SELECT " + strconv.Itoa(len(e.rows))), nil
+	}
+	return pgconn.NewCommandTag(e.tag), nil
+}
+
+func (f *Fake) query(sql string, args []any, inTx bool) (pgx.Rows, error) {
+	e, err := f.next(Statement{SQL: sql, Args: args, InTx: inTx})
+	if err != nil {
+		return nil, err
+	}
+	return newFakeRows(e.columns, e.rows), nil
+}
+
+func (f *Fake) queryRow(sql string, args []any, inTx bool) pgx.Row {
+	rows, err := f.query(sql, args, inTx)
+	return &fakeRow{rows: rows, err: err}
+}
+
+func (f *Fake) copyFrom(table pgx.Identifier, columns []string, rowSrc pgx.CopyFromSource, inTx bool) (int64, error) {
+	var rows [][]any
+	for rowSrc.Next() {
+		values, err := rowSrc.Values()
+		if err != nil {
+			return 0, err
+		}
+		rows = append(rows, values)
+	}
+	if err := rowSrc.Err(); err != nil {
+		return 0, err
+	}
+	sql := "COPY " + table.Sanitize() + " (" + strings.Join(columns, ", ") + ") FROM STDIN"
+	if _, err := f.next(Statement{SQL: sql, Rows: rows, InTx: inTx}); err != nil {
+		return 0, err
+	}
+	return int64(len(rows)), nil
+}
+
+// fakeTx records transaction control. Top level transactions count towards Commits and Rollbacks.
+type fakeTx struct {
+	fake      *Fake
+	savepoint string
+	closed    bool
+}
+
+func (tx *fakeTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	if tx.closed {
+		return nil, pgx.ErrTxClosed
+	}
+	tx.fake.mu.Lock()
+	tx.fake.savepoints++
+	name := "sp_" + strconv.Itoa(tx.fake.savepoints)
+	tx.fake.mu.Unlock()
+	tx.fake.record(Statement{SQL: "SAVEPOINT " + name, InTx: true})
+	return &fakeTx{fake: tx.fake, savepoint: name}, nil
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+	if tx.savepoint != "" {
+		tx.fake.record(Statement{SQL: "RELEASE SAVEPOINT " + tx.savepoint, InTx: true})
+		return nil
+	}
+	tx.fake.mu.Lock()
+	var err error
+	if len(tx.fake.commitErrs) > 0 {
+		err, tx.fake.commitErrs = tx.fake.commitErrs[0], tx.fake.commitErrs[1:]
+	}
+	tx.fake.mu.Unlock()
+	if err != nil {
+		tx.fake.record(Statement{SQL: "ROLLBACK", InTx: true})
+		tx.fake.mu.Lock()
+		tx.fake.rollbacks++
+		tx.fake.mu.Unlock()
+		return err
+	}
+	tx.fake.record(Statement{SQL: "COMMIT", InTx: true})
+	tx.fake.mu.Lock()
+	tx.fake.commits++
+	tx.fake.mu.Unlock()
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+	if tx.savepoint != "" {
+		tx.fake.record(Statement{SQL: "ROLLBACK TO SAVEPOINT " + tx.savepoint, InTx: true})
+		return nil
+	}
+	tx.fake.record(Statement{SQL: "ROLLBACK", InTx: true})
+	tx.fake.mu.Lock()
+	tx.fake.rollbacks++
+	tx.fake.mu.Unlock()
+	return nil
+}
+
+func (tx *fakeTx) CopyFrom(
+	ctx context.Context, table pgx.Identifier, columns []string, rowSrc pgx.CopyFromSource,
+) (int64, error) {
+	return tx.fake.copyFrom(table, columns, rowSrc, true)
+}
+
+func (tx *fakeTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return &fakeBatchResults{fake: tx.fake, queries: b.QueuedQueries, inTx: true}
+}
+
+func (tx *fakeTx) LargeObjects() pgx.LargeObjects {
+	return pgx.LargeObjects{}
+}
+
+func (tx *fakeTx) Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+	return &pgconn.StatementDescription{Name: name, SQL: sql}, nil
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return tx.fake.exec(sql, args, true)
+}
+
+func (tx *fakeTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return tx.fake.query(sql, args, true)
+}
+
+func (tx *fakeTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return tx.fake.queryRow(sql, args, true)
+}
+
+func (tx *fakeTx) Conn() *pgx.Conn {
+	return nil
+}
+
+type fakeBatchResults struct {
+	fake    *Fake
+	queries []*pgx.QueuedQuery
+	inTx    bool
+}
+
+func (b *fakeBatchResults) pop() (*pgx.QueuedQuery, error) {
+	if len(b.queries) == 0 {
+		return nil, errors.New("dbtest: no more queued queries in batch")
+	}
+	query := b.queries[0]
+	b.queries = b.queries[1:]
+	return query, nil
+}
+
+func (b *fakeBatchResults) Exec() (pgconn.CommandTag, error) {
+	query, err := b.pop()
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return b.fake.exec(query.SQL, query.Arguments, b.inTx)
+}
+
+func (b *fakeBatchResults) Query() (pgx.Rows, error) {
+	query, err := b.pop()
+	if err != nil {
+		return nil, err
+	}
+	return b.fake.query(query.SQL, query.Arguments, b.inTx)
+}
+
+func (b *fakeBatchResults) QueryRow() pgx.Row {
+	query, err := b.pop()
+	if err != nil {
+		return &fakeRow{err: err}
+	}
+	return b.fake.queryRow(query.SQL, query.Arguments, b.inTx)
+}
+
+// Close executes the queued queries not read yet, like pgx does.
+func (b *fakeBatchResults) Close() error {
+	var errs []error
+	for len(b.queries) > 0 {
+		if _, err := b.Exec(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type fakeRows struct {
+	fields []pgconn.FieldDescription
+	rows   [][]any
+	index  int
+	err    error
+}
+
+func newFakeRows(columns []string, rows [][]any) *fakeRows {
+	fields := make([]pgconn.FieldDescription, len(columns))
+	for i, column := range columns {
+		fields[i] = pgconn.FieldDescription{Name: column}
+	}
+	return &fakeRows{fields: fields, rows: rows, index: -1}
+}
+
+func (r *fakeRows) Close() {
+	r.index = len(r.rows)
+}
+
+func (r *fakeRows) Err() error {
+	return r.err
+}
+
+func (r *fakeRows) CommandTag() pgconn.CommandTag {
+	return pgconn.NewCommandTag("SELECT " + strconv.Itoa(len(r.rows)))
+}
+
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription {
+	return r.fields
+}
+
+func (r *fakeRows) Next() bool {
+	if r.err != nil || r.index >= len(r.rows) {
+		return false
+	}
+	r.index++
+	return r.index < len(r.rows)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	values, err := r.Values()
+	if err != nil {
+		return err
+	}
+	if len(dest) != len(values) {
+		r.err = fmt.Errorf("dbtest: scan %d values into %d destinations", len(values), len(dest))
+		return r.err
+	}
+	for i, value := range values {
+		if err = assign(dest[i], value); err != nil {
+			r.err = fmt.Errorf("dbtest: scan column %d: %w", i, err)
+			return r.err
+		}
+	}
+	return nil
+}
+
+func (r *fakeRows) Values() ([]any, error) {
+	if r.index < 0 || r.index >= len(r.rows) {
+		return nil, errors.New("dbtest: no current row")
+	}
+	return r.rows[r.index], nil
+}
+
+func (r *fakeRows) RawValues() [][]byte {
+	return nil
+}
+
+func (r *fakeRows) Conn() *pgx.Conn {
+	return nil
+}
+
+type fakeRow struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r *fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	return r.rows.Scan(dest...)
+}
+
+// assign stores value into the pointer dest, converting between compatible kinds.
+func assign(dest any, value any) error {
+	if scanner, ok := dest.(sql.Scanner); ok {
+		return scanner.Scan(value)
+	}
+	target := reflect.ValueOf(dest)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("destination %T is not a non-nil pointer", dest)
+	}
+	target = target.Elem()
+	if value == nil {
+		target.SetZero()
+		return nil
+	}
+	source := reflect.ValueOf(value)
+	if target.Kind() == reflect.Pointer && !source.Type().AssignableTo(target.Type()) {
+		ptr := reflect.New(target.Type().Elem())
+		if err := assign(ptr.Interface(), value); err != nil {
+			return err
+		}
+		target.Set(ptr)
+		return nil
+	}
+	switch {
+	case source.Type().AssignableTo(target.Type()):
+		target.Set(source)
+	case convertible(source.Kind(), target.Kind()) && source.Type().ConvertibleTo(target.Type()):
+		target.Set(source.Convert(target.Type()))
+	default:
+		return fmt.Errorf("cannot assign %T to %s", value, target.Type())
+	}
+	return nil
+}
+
+// convertible excludes conversions that compile but change meaning, like int to string.
+func convertible(from, to reflect.Kind) bool {
+	numeric := func(kind reflect.Kind) bool {
+		return kind >= reflect.Int && kind <= reflect.Float64
+	}
+	return numeric(from) && numeric(to) || from == to
+}
+
+func normalizeSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
@@ -0,0 +1,92 @@
+package dbtest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	ID    int64   `db:"id"`
+	Email string  `db:"email"`
+	Name  *string `db:"name"`
+}
+
+func TestFake_RunInTxCommitsAndRollsBack(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	ctx := context.Background()
+	fake.Expect(dbtest.Exact("INSERT INTO users (email) VALUES ($1)")).WithArgs("a@example.com").WillReturnTag("INSERT 0 1")
+	fake.Expect(dbtest.Regex(`^UPDATE users`)).WithArgs(dbtest.AnyArg).WillReturnError(errors.New("boom"))
+
+	err := fake.RunInTx(ctx, func(ctx context.Context) error {
+		tag, err := fake.GetConnectionFromCtx(ctx).Exec(ctx, "INSERT INTO users  (email)\n VALUES ($1)", "a@example.com")
+		require.NoError(t, err)
+		require.EqualValues(t, 1, tag.RowsAffected())
+		// The failing savepoint leaves the outer transaction usable
+		err = fake.RunInTx(ctx, func(ctx context.Context) error {
+			_, err := fake.GetConnectionFromCtx(ctx).Exec(ctx, "UPDATE users SET name = $1", "x")
+			return err
+		})
+		require.EqualError(t, err, "boom")
+		return nil
+	})
+	require.NoError(t, err)
+
+	var sql []string
+	for _, statement := range fake.Statements() {
+		sql = append(sql, statement.SQL)
+	}
+	require.Equal(t, []string{
+		"BEGIN",
+		"INSERT INTO users  (email)\n VALUES ($1)",
+		"SAVEPOINT sp_1",
+		"UPDATE users SET name = $1",
+		"ROLLBACK TO SAVEPOINT sp_1",
+		"COMMIT",
+	}, sql)
+	require.Equal(t, 1, fake.Commits())
+	require.Equal(t, 0, fake.Rollbacks())
+}
+
+func TestFake_FailNextCommit(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	commitErr := errors.New("serialization failure")
+	fake.FailNextCommit(commitErr)
+
+	committed := false
+	err := fake.RunInTx(context.Background(), func(ctx context.Context) error {
+		return db.OnCommit(ctx, func(context.Context) error {
+			committed = true
+			return nil
+		})
+	})
+	require.ErrorIs(t, err, commitErr)
+	require.False(t, committed)
+	require.Equal(t, 1, fake.Rollbacks())
+}
+
+func TestFake_QueryHelpers(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	ctx := context.Background()
+	name := "Alice"
+	fake.Expect(dbtest.Regex(`FROM users WHERE id`)).WithArgs(int64(1)).
+		WillReturnRows([]string{"id", "email", "name"}, []any{int32(1), "a@example.com", name})
+	fake.Expect(dbtest.Regex(`FROM users WHERE id`)).WithArgs(int64(2)).
+		WillReturnRows([]string{"id", "email", "name"})
+	fake.Expect(dbtest.Exact("SELECT count(*) FROM users")).WillReturnRows([]string{"count"}, []any{int64(3)})
+
+	found, err := db.QueryOne[user](ctx, fake, "SELECT id, email, name FROM users WHERE id = $1", int64(1))
+	require.NoError(t, err)
+	require.Equal(t, user{ID: 1, Email: "a@example.com", Name: &name}, found)
+
+	_, err = db.QueryOne[user](ctx, fake, "SELECT id, email, name FROM users WHERE id = $1", int64(2))
+	require.ErrorIs(t, err, db.ErrNotFound)
+
+	count, err := db.QueryOne[int](ctx, fake, "SELECT count(*) FROM users")
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+}
@@ -0,0 +1,24 @@
+package db
+
+import "github.com/IndexStorm/common-go/db/pgerr"
+
+// Classified database errors, see pgerr for details. They are aliases so that
+// errors.Is works regardless of the package the caller refers to.
+var (
+	ErrUniqueViolation     = pgerr.ErrUniqueViolation
+	ErrForeignKeyViolation = pgerr.ErrForeignKeyViolation
+	ErrCheckViolation      = pgerr.ErrCheckViolation
+	ErrNotNullViolation    = pgerr.ErrNotNullViolation
+	ErrExclusionViolation  = pgerr.ErrExclusionViolation
+	ErrSerialization       = pgerr.ErrSerialization
+	ErrDeadlock            = pgerr.ErrDeadlock
+	ErrQueryCanceled       = pgerr.ErrQueryCanceled
+	ErrConnection          = pgerr.ErrConnection
+)
+
+type Error = pgerr.Error
+
+// ClassifyError wraps err into *Error when it is a recognized database error, otherwise it returns err unchanged.
+func ClassifyError(err error) error {
+	return pgerr.Classify(err)
+}
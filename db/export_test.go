@@ -0,0 +1,37 @@
+package db
+
+import "time"
+
+func SetTxAttemptTracer(wrapper PgxPoolWrapper, tracer TxAttemptTracer) {
+	wrapper.(*pgxPoolWrapper).attemptTracer = tracer
+}
+
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	return p.backoff(attempt)
+}
+
+func CheckReplicas(wrapper ReplicatedPgxPoolWrapper) {
+	wrapper.(*replicatedPoolWrapper).checkReplicas()
+}
+
+type PoolUsage struct {
+	Acquired      int32
+	Idle          int32
+	Max           int32
+	EmptyAcquires int64
+}
+
+// CheckExhaustion classifies successive usage samples the way Check does.
+func CheckExhaustion(cfg HealthCheckerConfig) func(now time.Time, usage PoolUsage) HealthResult {
+	checker := &poolHealthChecker{config: cfg}
+	return func(now time.Time, usage PoolUsage) HealthResult {
+		result := HealthResult{Status: HealthStatusUp, Details: make(map[string]string)}
+		checker.checkExhaustion(&result, now, poolUsage{
+			acquired:      usage.Acquired,
+			idle:          usage.Idle,
+			max:           usage.Max,
+			emptyAcquires: usage.EmptyAcquires,
+		})
+		return result
+	}
+}
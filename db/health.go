@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type HealthStatus string
+
+const (
+	HealthStatusUp       HealthStatus = "UP"
+	HealthStatusDegraded HealthStatus = "DEGRADED"
+	HealthStatusDown     HealthStatus = "DOWN"
+)
+
+const DefaultHealthPingTimeout = time.Second
+
+type HealthResult struct {
+	Status  HealthStatus      `json:"status"`
+	Latency time.Duration     `json:"latency"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// MarshalJSON renders Latency as a duration string such as "1.5ms" rather than nanoseconds.
+func (r HealthResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Status  HealthStatus      `json:"status"`
+		Latency string            `json:"latency"`
+		Details map[string]string `json:"details,omitempty"`
+	}{Status: r.Status, Latency: r.Latency.String(), Details: r.Details})
+}
+
+// IsReady reports whether the database can serve traffic, a degraded pool still can.
+func (r HealthResult) IsReady() bool {
+	return r.Status != HealthStatusDown
+}
+
+type HealthCheckerConfig struct {
+	PingTimeout time.Duration
+	// RequirePrimary marks the database down while it is in recovery, i.e. it is a read-only standby.
+	RequirePrimary bool
+	// ExhaustionThreshold marks the pool degraded once every connection has been in use for longer than it.
+	// Zero disables the check.
+	ExhaustionThreshold time.Duration
+}
+
+type HealthChecker interface {
+	Check(ctx context.Context) HealthResult
+}
+
+type poolHealthChecker struct {
+	pool             *pgxpool.Pool
+	config           HealthCheckerConfig
+	mu               sync.Mutex
+	exhaustedSince   time.Time
+	lastEmptyAcquire int64
+}
+
+func NewHealthChecker(pool *pgxpool.Pool, cfg HealthCheckerConfig) HealthChecker {
+	if cfg.PingTimeout <= 0 {
+		cfg.PingTimeout = DefaultHealthPingTimeout
+	}
+	return &poolHealthChecker{pool: pool, config: cfg}
+}
+
+func (c *poolHealthChecker) Check(ctx context.Context) HealthResult {
+	result := HealthResult{Status: HealthStatusUp, Details: make(map[string]string)}
+	// Exhaustion is sampled before pinging, the ping itself needs a free connection
+	c.checkExhaustion(&result, time.Now(), usageOf(c.pool.Stat()))
+	ctx, cancel := context.WithTimeout(ctx, c.config.PingTimeout)
+	defer cancel()
+	start := time.Now()
+	var err error
+	if c.config.RequirePrimary {
+		var inRecovery bool
+		err = c.pool.QueryRow(ctx, "SELECT pg_is_in_recovery()").Scan(&inRecovery)
+		if err == nil {
+			result.Details["in_recovery"] = strconv.FormatBool(inRecovery)
+			if inRecovery {
+				result.Status = HealthStatusDown
+			}
+		}
+	} else {
+		err = c.pool.Ping(ctx)
+	}
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Status = HealthStatusDown
+		result.Details["error"] = err.Error()
+	}
+	stat := c.pool.Stat()
+	result.Details["acquired_conns"] = strconv.Itoa(int(stat.AcquiredConns()))
+	result.Details["idle_conns"] = strconv.Itoa(int(stat.IdleConns()))
+	result.Details["max_conns"] = strconv.Itoa(int(stat.MaxConns()))
+	return result
+}
+
+// poolUsage holds the pgxpool.Stat counters exhaustion is derived from.
+type poolUsage struct {
+	acquired      int32
+	idle          int32
+	max           int32
+	emptyAcquires int64
+}
+
+func usageOf(stat *pgxpool.Stat) poolUsage {
+	return poolUsage{
+		acquired:      stat.AcquiredConns(),
+		idle:          stat.IdleConns(),
+		max:           stat.MaxConns(),
+		emptyAcquires: stat.EmptyAcquireCount(),
+	}
+}
+
+func (c *poolHealthChecker) checkExhaustion(result *HealthResult, now time.Time, usage poolUsage) {
+	exhaustedFor := c.exhaustedFor(now, usage)
+	if exhaustedFor <= 0 {
+		return
+	}
+	result.Details["exhausted_for"] = exhaustedFor.String()
+	if c.config.ExhaustionThreshold > 0 && exhaustedFor > c.config.ExhaustionThreshold {
+		result.Status = HealthStatusDegraded
+	}
+}
+
+// exhaustedFor returns for how long the pool has had no spare connection, as observed by consecutive checks.
+func (c *poolHealthChecker) exhaustedFor(now time.Time, usage poolUsage) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	exhausted := usage.acquired >= usage.max || usage.emptyAcquires > c.lastEmptyAcquire && usage.idle == 0
+	c.lastEmptyAcquire = usage.emptyAcquires
+	if !exhausted {
+		c.exhaustedSince = time.Time{}
+		return 0
+	}
+	if c.exhaustedSince.IsZero() {
+		c.exhaustedSince = now
+	}
+	return now.Sub(c.exhaustedSince)
+}
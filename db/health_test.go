@@ -0,0 +1,73 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthResult_MarshalJSON(t *testing.T) {
+	data, err := json.Marshal(db.HealthResult{Status: db.HealthStatusUp, Latency: time.Millisecond * 1500})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"status":"UP","latency":"1.5s"}`, string(data))
+}
+
+func TestHealthChecker_Exhaustion(t *testing.T) {
+	start := time.Now()
+	type sample struct {
+		after        time.Duration
+		usage        db.PoolUsage
+		status       db.HealthStatus
+		exhaustedFor string
+	}
+	tests := []struct {
+		name    string
+		samples []sample
+	}{
+		{
+			name: "spare connections",
+			samples: []sample{
+				{usage: db.PoolUsage{Acquired: 2, Idle: 2, Max: 4}, status: db.HealthStatusUp},
+				{after: time.Minute, usage: db.PoolUsage{Acquired: 3, Idle: 1, Max: 4}, status: db.HealthStatusUp},
+			},
+		},
+		{
+			name: "all acquired beyond threshold",
+			samples: []sample{
+				{usage: db.PoolUsage{Acquired: 4, Max: 4}, status: db.HealthStatusUp},
+				{after: time.Second * 5, usage: db.PoolUsage{Acquired: 4, Max: 4}, status: db.HealthStatusUp, exhaustedFor: "5s"},
+				{after: time.Second * 11, usage: db.PoolUsage{Acquired: 4, Max: 4}, status: db.HealthStatusDegraded, exhaustedFor: "11s"},
+			},
+		},
+		{
+			name: "recovers once a connection is free",
+			samples: []sample{
+				{usage: db.PoolUsage{Acquired: 4, Max: 4}, status: db.HealthStatusUp},
+				{after: time.Second * 11, usage: db.PoolUsage{Acquired: 4, Max: 4}, status: db.HealthStatusDegraded, exhaustedFor: "11s"},
+				{after: time.Second * 12, usage: db.PoolUsage{Acquired: 3, Idle: 1, Max: 4}, status: db.HealthStatusUp},
+			},
+		},
+		{
+			name: "empty acquires without idle connections",
+			samples: []sample{
+				{usage: db.PoolUsage{Acquired: 2, Max: 4, EmptyAcquires: 1}, status: db.HealthStatusUp},
+				{after: time.Second * 11, usage: db.PoolUsage{Acquired: 2, Max: 4, EmptyAcquires: 7}, status: db.HealthStatusDegraded, exhaustedFor: "11s"},
+				// No new empty acquire since the previous check
+				{after: time.Second * 12, usage: db.PoolUsage{Acquired: 2, Max: 4, EmptyAcquires: 7}, status: db.HealthStatusUp},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := db.CheckExhaustion(db.HealthCheckerConfig{ExhaustionThreshold: time.Second * 10})
+			for i, s := range tt.samples {
+				result := check(start.Add(s.after), s.usage)
+				require.Equal(t, s.status, result.Status, "sample %d", i)
+				require.Equal(t, s.exhaustedFor, result.Details["exhausted_for"], "sample %d", i)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrNotInTx = errors.New("pgx: context does not carry a transaction")
+
+type txHooksCtxKey struct{}
+
+type txHooks struct {
+	mu         sync.Mutex
+	onCommit   []func(context.Context) error
+	onRollback []func(context.Context) error
+}
+
+// OnCommit registers fn to run once the transaction carried by ctx is committed.
+// Inside a savepoint fn waits for the outermost transaction, and it is dropped if the savepoint rolls back.
+func OnCommit(ctx context.Context, fn func(context.Context) error) error {
+	hooks, ok := ctx.Value(txHooksCtxKey{}).(*txHooks)
+	if !ok {
+		return ErrNotInTx
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.onCommit = append(hooks.onCommit, fn)
+	return nil
+}
+
+// OnRollback registers fn to run once the work done in the transaction carried by ctx is rolled back,
+// either by rolling back the transaction itself or a savepoint it belongs to.
+func OnRollback(ctx context.Context, fn func(context.Context) error) error {
+	hooks, ok := ctx.Value(txHooksCtxKey{}).(*txHooks)
+	if !ok {
+		return ErrNotInTx
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.onRollback = append(hooks.onRollback, fn)
+	return nil
+}
+
+// WithHookErrorHandler receives errors returned by OnCommit and OnRollback callbacks.
+// Such errors never change the result of RunInTx, by default they are only recorded on the active span.
+func WithHookErrorHandler(handler func(ctx context.Context, err error)) TxOption {
+	return &txHookErrorHandlerOption{handler: handler}
+}
+
+type txHookErrorHandlerOption struct {
+	handler func(ctx context.Context, err error)
+}
+
+func (o *txHookErrorHandlerOption) apply(cfg *txConfig) {
+	cfg.hookErrorHandler = o.handler
+}
+
+func (h *txHooks) take() ([]func(context.Context) error, []func(context.Context) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	onCommit, onRollback := h.onCommit, h.onRollback
+	h.onCommit, h.onRollback = nil, nil
+	return onCommit, onRollback
+}
+
+// committed runs the commit callbacks, or hands all callbacks over to the parent when a savepoint is released.
+func (h *txHooks) committed(ctx context.Context, parent *txHooks, cfg txConfig) {
+	onCommit, onRollback := h.take()
+	if parent != nil {
+		parent.mu.Lock()
+		parent.onCommit = append(parent.onCommit, onCommit...)
+		parent.onRollback = append(parent.onRollback, onRollback...)
+		parent.mu.Unlock()
+		return
+	}
+	runTxHooks(ctx, "commit", onCommit, cfg)
+}
+
+func (h *txHooks) rolledBack(ctx context.Context, cfg txConfig) {
+	_, onRollback := h.take()
+	runTxHooks(ctx, "rollback", onRollback, cfg)
+}
+
+func runTxHooks(ctx context.Context, stage string, hooks []func(context.Context) error, cfg txConfig) {
+	for _, hook := range hooks {
+		if err := runTxHook(ctx, hook); err != nil {
+			err = fmt.Errorf("on %s hook: %w", stage, err)
+			if cfg.hookErrorHandler != nil {
+				cfg.hookErrorHandler(ctx, err)
+			} else if span := trace.SpanFromContext(ctx); span.IsRecording() {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+		}
+	}
+}
+
+func runTxHook(ctx context.Context, hook func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return hook(ctx)
+}
@@ -0,0 +1,121 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/stretchr/testify/require"
+)
+
+// recordHooks registers hooks on ctx that append "<name> commit" or "<name> rollback" to fired.
+func recordHooks(t *testing.T, ctx context.Context, name string, fired *[]string) {
+	t.Helper()
+	require.NoError(t, db.OnCommit(ctx, func(context.Context) error {
+		*fired = append(*fired, name+" commit")
+		return nil
+	}))
+	require.NoError(t, db.OnRollback(ctx, func(context.Context) error {
+		*fired = append(*fired, name+" rollback")
+		return nil
+	}))
+}
+
+func TestTxHooks_ReleasedSavepointFollowsOuterRollback(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	boom := errors.New("boom")
+	var fired []string
+	err := fake.RunInTx(context.Background(), func(ctx context.Context) error {
+		recordHooks(t, ctx, "outer", &fired)
+		err := fake.RunInTx(ctx, func(ctx context.Context) error {
+			recordHooks(t, ctx, "inner", &fired)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Empty(t, fired)
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+	require.Equal(t, []string{"outer rollback", "inner rollback"}, fired)
+	require.Equal(t, []string{"BEGIN", "SAVEPOINT sp_1", "RELEASE SAVEPOINT sp_1", "ROLLBACK"}, statementSQL(fake))
+}
+
+func TestTxHooks_RolledBackSavepoint(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	boom := errors.New("boom")
+	var fired []string
+	err := fake.RunInTx(context.Background(), func(ctx context.Context) error {
+		recordHooks(t, ctx, "outer", &fired)
+		err := fake.RunInTx(ctx, func(ctx context.Context) error {
+			recordHooks(t, ctx, "inner", &fired)
+			return boom
+		})
+		require.ErrorIs(t, err, boom)
+		require.Equal(t, []string{"inner rollback"}, fired)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"inner rollback", "outer commit"}, fired)
+}
+
+func TestTxHooks_FailedCommitFiresRollback(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	commitErr := errors.New("serialization failure")
+	fake.FailNextCommit(commitErr)
+	var fired []string
+	err := fake.RunInTx(context.Background(), func(ctx context.Context) error {
+		recordHooks(t, ctx, "tx", &fired)
+		return nil
+	})
+	require.ErrorIs(t, err, commitErr)
+	require.Equal(t, []string{"tx rollback"}, fired)
+}
+
+func TestTxHooks_ErrorsDoNotChangeResult(t *testing.T) {
+	hookErr := errors.New("publish failed")
+	tests := []struct {
+		name string
+		hook func(context.Context) error
+		want string
+	}{
+		{
+			name: "error",
+			hook: func(context.Context) error { return hookErr },
+			want: "on commit hook: publish failed",
+		},
+		{
+			name: "panic",
+			hook: func(context.Context) error { panic("nil map") },
+			want: "on commit hook: panic: nil map",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := dbtest.NewFake(t)
+			var handled []error
+			next := false
+			err := fake.RunInTx(context.Background(), func(ctx context.Context) error {
+				require.NoError(t, db.OnCommit(ctx, tt.hook))
+				return db.OnCommit(ctx, func(context.Context) error {
+					next = true
+					return nil
+				})
+			}, db.WithHookErrorHandler(func(_ context.Context, err error) {
+				handled = append(handled, err)
+			}))
+			require.NoError(t, err)
+			require.True(t, next, "hooks after a failed one still run")
+			require.Len(t, handled, 1)
+			require.EqualError(t, handled[0], tt.want)
+			require.Equal(t, 1, fake.Commits())
+		})
+	}
+}
+
+func TestTxHooks_OutsideTransaction(t *testing.T) {
+	noop := func(context.Context) error { return nil }
+	require.ErrorIs(t, db.OnCommit(context.Background(), noop), db.ErrNotInTx)
+	require.ErrorIs(t, db.OnRollback(context.Background(), noop), db.ErrNotInTx)
+}
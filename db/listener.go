@@ -0,0 +1,226 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/IndexStorm/common-go/termination"
+	"github.com/goccy/go-json"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+const (
+	DefaultListenerMinBackoff = time.Millisecond * 100
+	DefaultListenerMaxBackoff = time.Second * 30
+)
+
+type NotificationHandler func(ctx context.Context, notification *pgconn.Notification) error
+
+type Listener interface {
+	termination.Shutdowner
+	// Handle subscribes handler to channel. Handlers may be added at any time, also after the listener started.
+	Handle(channel string, handler NotificationHandler)
+}
+
+type ListenerConfig struct {
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// OnReconnect is called after the listener re-subscribed on a new connection.
+	// Notifications sent while the connection was down are lost, use it to resync state.
+	OnReconnect func(ctx context.Context)
+	Logger      zerolog.Logger
+}
+
+type listener struct {
+	pool     *pgxpool.Pool
+	config   ListenerConfig
+	mu       sync.Mutex
+	handlers map[string][]NotificationHandler
+	wake     chan struct{}
+	cancel   context.CancelFunc
+	stopped  chan struct{}
+}
+
+// NewListener starts listening on a dedicated connection acquired from pool and hijacked from it,
+// so it does not count against the pool size once established. The connection is re-established
+// with exponential backoff after a failure, re-issuing LISTEN for every registered channel.
+func NewListener(pool *pgxpool.Pool, cfg ListenerConfig) Listener {
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultListenerMinBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultListenerMaxBackoff
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &listener{
+		pool:     pool,
+		config:   cfg,
+		handlers: make(map[string][]NotificationHandler),
+		wake:     make(chan struct{}, 1),
+		cancel:   cancel,
+		stopped:  make(chan struct{}),
+	}
+	go l.run(ctx)
+	return l
+}
+
+func (l *listener) Handle(channel string, handler NotificationHandler) {
+	l.mu.Lock()
+	l.handlers[channel] = append(l.handlers[channel], handler)
+	l.mu.Unlock()
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (l *listener) Shutdown(ctx context.Context) error {
+	l.cancel()
+	select {
+	case <-l.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *listener) run(ctx context.Context) {
+	defer close(l.stopped)
+	backoff := l.config.MinBackoff
+	connected := false
+	for {
+		err := l.listen(ctx, connected, func() {
+			connected = true
+			backoff = l.config.MinBackoff
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		l.config.Logger.Warn().Err(err).Dur("backoff", backoff).Msg("listener connection lost")
+		if sleepContext(ctx, backoff) != nil {
+			return
+		}
+		backoff = min(backoff*2, l.config.MaxBackoff)
+	}
+}
+
+// listen holds a single connection until it fails or ctx is canceled.
+func (l *listener) listen(ctx context.Context, reconnect bool, subscribed func()) error {
+	poolConn, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	conn := poolConn.Hijack()
+	defer conn.Close(context.Background())
+	listening := make(map[string]bool)
+	for {
+		if err = l.subscribe(ctx, conn, listening); err != nil {
+			return err
+		}
+		if reconnect {
+			reconnect = false
+			if l.config.OnReconnect != nil {
+				l.config.OnReconnect(ctx)
+			}
+		}
+		subscribed()
+		notification, err := l.wait(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("wait for notification: %w", err)
+		}
+		if notification != nil {
+			l.dispatch(ctx, notification)
+		}
+	}
+}
+
+func (l *listener) subscribe(ctx context.Context, conn *pgx.Conn, listening map[string]bool) error {
+	l.mu.Lock()
+	channels := make([]string, 0, len(l.handlers))
+	for channel := range l.handlers {
+		if !listening[channel] {
+			channels = append(channels, channel)
+		}
+	}
+	l.mu.Unlock()
+	for _, channel := range channels {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return fmt.Errorf("listen %s: %w", channel, err)
+		}
+		listening[channel] = true
+	}
+	return nil
+}
+
+// wait blocks until a notification arrives. It returns nil without an error when woken up by Handle.
+func (l *listener) wait(ctx context.Context, conn *pgx.Conn) (*pgconn.Notification, error) {
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-l.wake:
+			cancel()
+		case <-waitCtx.Done():
+		}
+	}()
+	notification, err := conn.WaitForNotification(waitCtx)
+	if err != nil && ctx.Err() == nil && waitCtx.Err() != nil && !conn.IsClosed() {
+		return nil, nil
+	}
+	return notification, err
+}
+
+func (l *listener) dispatch(ctx context.Context, notification *pgconn.Notification) {
+	l.mu.Lock()
+	handlers := l.handlers[notification.Channel]
+	l.mu.Unlock()
+	for _, handler := range handlers {
+		if err := runNotificationHandler(ctx, handler, notification); err != nil {
+			l.config.Logger.Error().Err(err).Str("channel", notification.Channel).Msg("notification handler failed")
+		}
+	}
+}
+
+func runNotificationHandler(
+	ctx context.Context, handler NotificationHandler, notification *pgconn.Notification,
+) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, notification)
+}
+
+// HandleJSON subscribes a handler receiving payloads decoded from JSON, the counterpart of NotifyJSON.
+func HandleJSON[T any](l Listener, channel string, handler func(ctx context.Context, payload T) error) {
+	l.Handle(channel, func(ctx context.Context, notification *pgconn.Notification) error {
+		var payload T
+		if err := json.Unmarshal([]byte(notification.Payload), &payload); err != nil {
+			return fmt.Errorf("unmarshal payload: %w", err)
+		}
+		return handler(ctx, payload)
+	})
+}
+
+// Notify sends payload with pg_notify. Inside a transaction the notification is delivered only on commit.
+func Notify(ctx context.Context, pool PgxPoolWrapper, channel string, payload string) error {
+	if _, err := pool.GetConnectionFromCtx(ctx).Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload); err != nil {
+		return fmt.Errorf("notify %s: %w", channel, err)
+	}
+	return nil
+}
+
+// NotifyJSON sends payload encoded as JSON, see Notify.
+func NotifyJSON[T any](ctx context.Context, pool PgxPoolWrapper, channel string, payload T) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+	return Notify(ctx, pool, channel, string(data))
+}
@@ -0,0 +1,80 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListener_ReconnectsAndResubscribes(t *testing.T) {
+	pool, wrapper := dbtest.New(t, schemaDir)
+	ctx := context.Background()
+	reconnected := make(chan struct{}, 1)
+	listener := db.NewListener(pool, db.ListenerConfig{
+		MinBackoff: time.Millisecond * 10,
+		MaxBackoff: time.Millisecond * 50,
+		OnReconnect: func(context.Context) {
+			reconnected <- struct{}{}
+		},
+	})
+	t.Cleanup(func() {
+		require.NoError(t, listener.Shutdown(context.Background()))
+	})
+	received := make(chan string, 10)
+	db.HandleJSON(listener, "items", func(_ context.Context, name string) error {
+		received <- name
+		return nil
+	})
+
+	// LISTEN is issued asynchronously, notify until the listener is subscribed
+	notifyUntilReceived(t, wrapper, received, "first")
+
+	var terminated bool
+	err := pool.QueryRow(ctx, "SELECT pg_terminate_backend(pid) FROM pg_stat_activity"+
+		" WHERE datname = current_database() AND query LIKE 'LISTEN%'").Scan(&terminated)
+	require.NoError(t, err)
+	require.True(t, terminated)
+	select {
+	case <-reconnected:
+	case <-time.After(time.Second * 10):
+		t.Fatal("listener did not reconnect")
+	}
+	require.NoError(t, db.NotifyJSON(ctx, wrapper, "items", "second"))
+	require.Equal(t, "second", receive(t, received))
+}
+
+func notifyUntilReceived(t *testing.T, wrapper db.PgxPoolWrapper, received <-chan string, payload string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		require.NoError(t, db.NotifyJSON(context.Background(), wrapper, "items", payload))
+		select {
+		case got := <-received:
+			return got == payload
+		case <-time.After(time.Millisecond * 100):
+			return false
+		}
+	}, time.Second*10, time.Millisecond*10)
+	// Drain duplicates of the notifications sent while waiting
+	for {
+		select {
+		case <-received:
+		case <-time.After(time.Millisecond * 200):
+			return
+		}
+	}
+}
+
+func receive(t *testing.T, received <-chan string) string {
+	t.Helper()
+	select {
+	case got := <-received:
+		return got
+	case <-time.After(time.Second * 10):
+		t.Fatal("notification not received")
+		return ""
+	}
+}
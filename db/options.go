@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"crypto/x509"
+	"runtime"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
+)
+
+type PoolOption interface {
+	apply(cfg *poolConfig)
+}
+
+type poolConfig struct {
+	connectTimeout        time.Duration
+	certPool              *x509.CertPool
+	tracers               []pgx.QueryTracer
+	maxConns              int32
+	minConns              int32
+	maxConnLifetime       time.Duration
+	maxConnLifetimeJitter time.Duration
+	maxConnIdleTime       time.Duration
+	healthCheckPeriod     time.Duration
+	beforeConnect         []func(context.Context, *pgx.ConnConfig) error
+	afterConnect          []func(context.Context, *pgx.Conn) error
+	beforeAcquire         []func(context.Context, *pgx.Conn) bool
+}
+
+func defaultPoolConfig() poolConfig {
+	return poolConfig{
+		connectTimeout:        time.Second * 10,
+		maxConns:              int32(4 * runtime.NumCPU()),
+		minConns:              int32(runtime.NumCPU()),
+		maxConnLifetime:       time.Minute * 10,
+		maxConnLifetimeJitter: time.Second * 15,
+		maxConnIdleTime:       time.Minute,
+		healthCheckPeriod:     time.Second * 70,
+	}
+}
+
+func (c *poolConfig) tracer() pgx.QueryTracer {
+	switch len(c.tracers) {
+	case 0:
+		return nil
+	case 1:
+		return c.tracers[0]
+	default:
+		return multitracer.New(c.tracers...)
+	}
+}
+
+func PoolWithConnectTimeout(timeout time.Duration) PoolOption {
+	return poolOptionFunc(func(cfg *poolConfig) {
+		cfg.connectTimeout = timeout
+	})
+}
+
+// PoolWithCertPool verifies the server certificate against pool, the connection string must enable TLS.
+func PoolWithCertPool(pool *x509.CertPool) PoolOption {
+	return poolOptionFunc(func(cfg *poolConfig) {
+		cfg.certPool = pool
+	})
+}
+
+// PoolWithTracer adds query tracers, several tracers are combined with multitracer.
+func PoolWithTracer(tracers ...pgx.QueryTracer) PoolOption {
+	return poolOptionFunc(func(cfg *poolConfig) {
+		for _, tracer := range tracers {
+			if tracer != nil {
+				cfg.tracers = append(cfg.tracers, tracer)
+			}
+		}
+	})
+}
+
+func PoolWithMaxConns(maxConns int32) PoolOption {
+	return poolOptionFunc(func(cfg *poolConfig) {
+		cfg.maxConns = maxConns
+	})
+}
+
+func PoolWithMinConns(minConns int32) PoolOption {
+	return poolOptionFunc(func(cfg *poolConfig) {
+		cfg.minConns = minConns
+	})
+}
+
+func PoolWithMaxConnLifetime(lifetime time.Duration, jitter time.Duration) PoolOption {
+	return poolOptionFunc(func(cfg *poolConfig) {
+		cfg.maxConnLifetime = lifetime
+		cfg.maxConnLifetimeJitter = jitter
+	})
+}
+
+func PoolWithMaxConnIdleTime(idleTime time.Duration) PoolOption {
+	return poolOptionFunc(func(cfg *poolConfig) {
+		cfg.maxConnIdleTime = idleTime
+	})
+}
+
+func PoolWithHealthCheckPeriod(period time.Duration) PoolOption {
+	return poolOptionFunc(func(cfg *poolConfig) {
+		cfg.healthCheckPeriod = period
+	})
+}
+
+// PoolWithBeforeConnect adds a hook run before every new connection, hooks run in the order they were added.
+func PoolWithBeforeConnect(hook func(context.Context, *pgx.ConnConfig) error) PoolOption {
+	return poolOptionFunc(func(cfg *poolConfig) {
+		cfg.beforeConnect = append(cfg.beforeConnect, hook)
+	})
+}
+
+// PoolWithAfterConnect adds a hook run after a connection is established, before it joins the pool.
+func PoolWithAfterConnect(hook func(context.Context, *pgx.Conn) error) PoolOption {
+	return poolOptionFunc(func(cfg *poolConfig) {
+		cfg.afterConnect = append(cfg.afterConnect, hook)
+	})
+}
+
+// PoolWithBeforeAcquire adds a hook run before a connection is handed out. Every hook must
+// return true, otherwise the connection is destroyed and another one is acquired.
+func PoolWithBeforeAcquire(hook func(context.Context, *pgx.Conn) bool) PoolOption {
+	return poolOptionFunc(func(cfg *poolConfig) {
+		cfg.beforeAcquire = append(cfg.beforeAcquire, hook)
+	})
+}
+
+type poolOptionFunc func(cfg *poolConfig)
+
+func (f poolOptionFunc) apply(cfg *poolConfig) {
+	f(cfg)
+}
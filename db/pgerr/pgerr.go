@@ -0,0 +1,127 @@
+package pgerr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrUniqueViolation     = errors.New("pg: unique violation")
+	ErrForeignKeyViolation = errors.New("pg: foreign key violation")
+	ErrCheckViolation      = errors.New("pg: check violation")
+	ErrNotNullViolation    = errors.New("pg: not null violation")
+	ErrExclusionViolation  = errors.New("pg: exclusion violation")
+	ErrSerialization       = errors.New("pg: serialization failure")
+	ErrDeadlock            = errors.New("pg: deadlock detected")
+	ErrQueryCanceled       = errors.New("pg: query canceled")
+	ErrConnection          = errors.New("pg: connection failure")
+)
+
+var kindNames = map[error]string{
+	ErrUniqueViolation:     "unique_violation",
+	ErrForeignKeyViolation: "foreign_key_violation",
+	ErrCheckViolation:      "check_violation",
+	ErrNotNullViolation:    "not_null_violation",
+	ErrExclusionViolation:  "exclusion_violation",
+	ErrSerialization:       "serialization_failure",
+	ErrDeadlock:            "deadlock_detected",
+	ErrQueryCanceled:       "query_canceled",
+	ErrConnection:          "connection_failure",
+}
+
+var codeKinds = map[string]error{
+	"23505": ErrUniqueViolation,
+	"23503": ErrForeignKeyViolation,
+	"23514": ErrCheckViolation,
+	"23502": ErrNotNullViolation,
+	"23P01": ErrExclusionViolation,
+	"40001": ErrSerialization,
+	"40P01": ErrDeadlock,
+	"57014": ErrQueryCanceled,
+	"57P01": ErrConnection, // admin_shutdown
+	"57P02": ErrConnection, // crash_shutdown
+	"57P03": ErrConnection, // cannot_connect_now
+}
+
+// Error is a classified database error. It matches its Kind with errors.Is
+// and still exposes the original *pgconn.PgError to errors.As.
+type Error struct {
+	Kind       error
+	Code       string
+	Constraint string
+	Table      string
+	Column     string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Classify wraps err into *Error when it is recognized, otherwise it returns err unchanged.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+	kind := Kind(err)
+	if kind == nil {
+		return err
+	}
+	result := &Error{Kind: kind, Err: err}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		result.Code = pgErr.Code
+		result.Constraint = pgErr.ConstraintName
+		result.Table = pgErr.TableName
+		result.Column = pgErr.ColumnName
+	}
+	return result
+}
+
+// Kind returns the sentinel error describing err, or nil when it is not recognized.
+func Kind(err error) error {
+	if err == nil {
+		return nil
+	}
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified.Kind
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if kind, ok := codeKinds[pgErr.Code]; ok {
+			return kind
+		}
+		// Class 08 — Connection Exception
+		if strings.HasPrefix(pgErr.Code, "08") {
+			return ErrConnection
+		}
+		return nil
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrQueryCanceled
+	}
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	if errors.As(err, &connectErr) || errors.As(err, &netErr) {
+		return ErrConnection
+	}
+	return nil
+}
+
+// Name returns a short snake_case label of the error kind, suitable for metrics and span attributes.
+func Name(err error) string {
+	return kindNames[Kind(err)]
+}
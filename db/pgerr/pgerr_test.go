@@ -0,0 +1,58 @@
+package pgerr_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/IndexStorm/common-go/db/pgerr"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify_UniqueViolation(t *testing.T) {
+	pgErr := &pgconn.PgError{Code: "23505", ConstraintName: "users_email_key", TableName: "users"}
+	err := pgerr.Classify(fmt.Errorf("insert user: %w", pgErr))
+
+	require.ErrorIs(t, err, pgerr.ErrUniqueViolation)
+	require.NotErrorIs(t, err, pgerr.ErrForeignKeyViolation)
+
+	var classified *pgerr.Error
+	require.ErrorAs(t, err, &classified)
+	require.Equal(t, "users_email_key", classified.Constraint)
+	require.Equal(t, "users", classified.Table)
+
+	var original *pgconn.PgError
+	require.ErrorAs(t, err, &original)
+	require.Same(t, pgErr, original)
+	require.Equal(t, "unique_violation", pgerr.Name(err))
+}
+
+func TestClassify_Kinds(t *testing.T) {
+	cases := []struct {
+		err  error
+		kind error
+	}{
+		{&pgconn.PgError{Code: "23503"}, pgerr.ErrForeignKeyViolation},
+		{&pgconn.PgError{Code: "23514"}, pgerr.ErrCheckViolation},
+		{&pgconn.PgError{Code: "23502"}, pgerr.ErrNotNullViolation},
+		{&pgconn.PgError{Code: "40001"}, pgerr.ErrSerialization},
+		{&pgconn.PgError{Code: "40P01"}, pgerr.ErrDeadlock},
+		{&pgconn.PgError{Code: "57014"}, pgerr.ErrQueryCanceled},
+		{&pgconn.PgError{Code: "08006"}, pgerr.ErrConnection},
+		{context.DeadlineExceeded, pgerr.ErrQueryCanceled},
+	}
+	for _, c := range cases {
+		require.ErrorIs(t, pgerr.Classify(c.err), c.kind)
+	}
+}
+
+func TestClassify_Unrecognized(t *testing.T) {
+	err := errors.New("boom")
+	require.Same(t, err, pgerr.Classify(err))
+
+	pgErr := &pgconn.PgError{Code: "42P01"}
+	require.Same(t, pgErr, pgerr.Classify(pgErr))
+	require.Nil(t, pgerr.Kind(pgErr))
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"crypto/x509"
 	"errors"
+	"fmt"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"runtime"
 	"time"
 )
 
@@ -20,8 +21,15 @@ type PgxConnection interface {
 }
 
 type PgxPoolWrapper interface {
-	RunInTx(ctx context.Context, fn func(context.Context) error) error
-	RunInTxWithOptions(ctx context.Context, opt pgx.TxOptions, fn func(context.Context) error) error
+	// RunInTx runs fn in a transaction carried by the context passed to fn, committed when fn returns nil.
+	// When ctx already carries a transaction, fn runs in a savepoint of it (PropagationNested) by default.
+	// Earlier versions began an independent transaction instead, pass WithPropagation(PropagationRequiresNew)
+	// to keep that behavior. When ctx has a deadline, statement_timeout follows it, see TxSettings.
+	// Database errors are returned classified, see ClassifyError.
+	RunInTx(ctx context.Context, fn func(context.Context) error, opts ...TxOption) error
+	RunInTxWithOptions(ctx context.Context, opt pgx.TxOptions, fn func(context.Context) error, opts ...TxOption) error
+	// GetConnectionFromCtx returns the transaction carried by ctx, or the pool outside a transaction.
+	// Its statements return errors as pgx does, pass them to ClassifyError or use QueryOne and friends.
 	GetConnectionFromCtx(ctx context.Context) PgxConnection
 }
 
@@ -36,70 +44,242 @@ func NewPgxConnection(
 	certPool *x509.CertPool,
 	timeout time.Duration,
 ) (*pgxpool.Pool, error) {
+	return NewPgxPoolWithOptions(ctx, conn,
+		PoolWithTracer(tracer),
+		PoolWithCertPool(certPool),
+		PoolWithConnectTimeout(timeout),
+	)
+}
+
+// NewPgxPoolWithOptions opens a pool with the library defaults overridden by opts.
+// It does not ping the database, see NewPgxPool for that.
+func NewPgxPoolWithOptions(ctx context.Context, conn string, opts ...PoolOption) (*pgxpool.Pool, error) {
+	cfg := defaultPoolConfig()
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
 	config, err := pgxpool.ParseConfig(conn)
 	if err != nil {
 		return nil, err
 	}
-	config.ConnConfig.ConnectTimeout = timeout
-	config.ConnConfig.Tracer = tracer
-	if certPool != nil {
+	config.ConnConfig.ConnectTimeout = cfg.connectTimeout
+	config.ConnConfig.Tracer = cfg.tracer()
+	if cfg.certPool != nil {
 		if config.ConnConfig.TLSConfig == nil {
 			return nil, ErrTlsConfigRequired
 		}
-		config.ConnConfig.TLSConfig.RootCAs = certPool
+		config.ConnConfig.TLSConfig.RootCAs = cfg.certPool
 		config.ConnConfig.TLSConfig.InsecureSkipVerify = false
 	}
-	config.MaxConnLifetime = time.Minute * 10
-	config.MaxConnLifetimeJitter = time.Second * 15
-	config.MaxConnIdleTime = time.Minute
-	config.MaxConns = int32(4 * runtime.NumCPU())
-	config.MinConns = int32(runtime.NumCPU())
-	config.HealthCheckPeriod = time.Second * 70
+	config.MaxConnLifetime = cfg.maxConnLifetime
+	config.MaxConnLifetimeJitter = cfg.maxConnLifetimeJitter
+	config.MaxConnIdleTime = cfg.maxConnIdleTime
+	config.MaxConns = cfg.maxConns
+	config.MinConns = cfg.minConns
+	config.HealthCheckPeriod = cfg.healthCheckPeriod
+	if len(cfg.beforeConnect) > 0 {
+		config.BeforeConnect = func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+			for _, hook := range cfg.beforeConnect {
+				if err := hook(ctx, connConfig); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	if len(cfg.afterConnect) > 0 {
+		config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			for _, hook := range cfg.afterConnect {
+				if err := hook(ctx, conn); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	if len(cfg.beforeAcquire) > 0 {
+		config.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+			for _, hook := range cfg.beforeAcquire {
+				if !hook(ctx, conn) {
+					return false
+				}
+			}
+			return true
+		}
+	}
 	return pgxpool.NewWithConfig(ctx, config)
 }
 
+// TxBeginner is a connection able to begin transactions, *pgxpool.Pool and *pgx.Conn both are.
+type TxBeginner interface {
+	PgxConnection
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
 func NewPgxPoolWrapper(pool *pgxpool.Pool) PgxPoolWrapper {
-	return &pgxPoolWrapper{pool: pool}
+	wrapper := &pgxPoolWrapper{conn: pool, pool: pool}
+	if pool != nil {
+		wrapper.attemptTracer = findTxAttemptTracer(pool.Config().ConnConfig.Tracer)
+	}
+	return wrapper
+}
+
+// NewPgxWrapper wraps a connection other than a pool, such as a single *pgx.Conn or a test fake.
+// The wrapper cannot pin connections, so session scoped helpers like AcquireSessionLock are not supported.
+func NewPgxWrapper(conn TxBeginner) PgxPoolWrapper {
+	return &pgxPoolWrapper{conn: conn}
 }
 
 type pgxPoolWrapper struct {
-	pool *pgxpool.Pool
+	conn TxBeginner
+	// pool is nil unless conn is a pool
+	pool          *pgxpool.Pool
+	attemptTracer TxAttemptTracer
 }
 
-func (r *pgxPoolWrapper) RunInTx(ctx context.Context, fn func(context.Context) error) error {
-	tx, err := r.pool.Begin(ctx)
-	if err != nil {
-		return err
+func (r *pgxPoolWrapper) RunInTx(ctx context.Context, fn func(context.Context) error, opts ...TxOption) error {
+	return r.RunInTxWithOptions(ctx, pgx.TxOptions{}, fn, opts...)
+}
+
+// RunInTxWithOptions runs fn according to the propagation selected with WithPropagation,
+// PropagationNested by default. Options are ignored when fn joins or nests into an outer transaction,
+// except TxSettings which also apply to savepoints. Database errors are returned classified, see ClassifyError.
+func (r *pgxPoolWrapper) RunInTxWithOptions(
+	ctx context.Context, opts pgx.TxOptions, fn func(context.Context) error, txOpts ...TxOption,
+) error {
+	return ClassifyError(r.runWithPropagation(ctx, opts, fn, newTxConfig(txOpts)))
+}
+
+func (r *pgxPoolWrapper) runWithPropagation(
+	ctx context.Context, opts pgx.TxOptions, fn func(context.Context) error, cfg txConfig,
+) error {
+	outer, hasOuter := ctx.Value(PgxConnectionCtxKey{}).(pgx.Tx)
+	switch cfg.propagation {
+	case PropagationRequired:
+		if hasOuter {
+			return fn(ctx)
+		}
+		return r.runInNewTx(ctx, opts, fn, cfg)
+	case PropagationRequiresNew:
+		return r.runInNewTx(ctx, opts, fn, cfg)
+	case PropagationNested:
+		if hasOuter {
+			savepoint, err := outer.Begin(ctx)
+			if err != nil {
+				return fmt.Errorf("create savepoint: %w", err)
+			}
+			parent, _ := ctx.Value(txHooksCtxKey{}).(*txHooks)
+			return runInTx(ctx, savepoint, fn, outer, parent, cfg)
+		}
+		return r.runInNewTx(ctx, opts, fn, cfg)
+	case PropagationNever:
+		if hasOuter {
+			return ErrTxNotAllowed
+		}
+		return fn(ctx)
+	default:
+		return fmt.Errorf("%w: %d", ErrInvalidPropagation, cfg.propagation)
 	}
-	defer tx.Rollback(ctx)
-	ctx = context.WithValue(ctx, PgxConnectionCtxKey{}, tx)
-	err = fn(ctx)
+}
+
+func (r *pgxPoolWrapper) runInNewTx(
+	ctx context.Context, opts pgx.TxOptions, fn func(context.Context) error, cfg txConfig,
+) error {
+	if cfg.retry == nil {
+		return r.beginAndRun(ctx, opts, fn, cfg)
+	}
+	for attempt := 1; ; attempt++ {
+		err := r.beginAndRun(ctx, opts, fn, cfg)
+		if r.attemptTracer != nil {
+			r.attemptTracer.TraceTxAttempt(ctx, attempt, err)
+		}
+		if err == nil || attempt >= cfg.retry.MaxAttempts || !cfg.retry.retryable(err) {
+			return err
+		}
+		if sleepErr := sleepContext(ctx, cfg.retry.backoff(attempt)); sleepErr != nil {
+			return errors.Join(err, sleepErr)
+		}
+	}
+}
+
+func (r *pgxPoolWrapper) beginAndRun(
+	ctx context.Context, opts pgx.TxOptions, fn func(context.Context) error, cfg txConfig,
+) error {
+	tx, err := r.conn.BeginTx(ctx, opts)
 	if err != nil {
 		return err
 	}
-	return tx.Commit(ctx)
+	return runInTx(ctx, tx, fn, nil, nil, cfg)
 }
 
-func (r *pgxPoolWrapper) RunInTxWithOptions(
-	ctx context.Context, opts pgx.TxOptions, fn func(context.Context) error,
+// runInTx runs fn in tx, a savepoint of outer when outer is not nil, and fires the hooks registered
+// within it. Hooks of a savepoint are handed over to parent on release and fired by the outermost transaction.
+func runInTx(
+	ctx context.Context, tx pgx.Tx, fn func(context.Context) error, outer pgx.Tx, parent *txHooks, cfg txConfig,
 ) error {
-	tx, err := r.pool.BeginTx(ctx, opts)
+	defer tx.Rollback(ctx)
+	hooks := &txHooks{}
+	txCtx := context.WithValue(ctx, PgxConnectionCtxKey{}, tx)
+	txCtx = context.WithValue(txCtx, txHooksCtxKey{}, hooks)
+	restore, err := applyTxSettings(ctx, tx, outer != nil, cfg)
 	if err != nil {
+		_ = tx.Rollback(ctx)
 		return err
 	}
-	defer tx.Rollback(ctx)
-	ctx = context.WithValue(ctx, PgxConnectionCtxKey{}, tx)
-	err = fn(ctx)
+	err = fn(txCtx)
+	if err != nil {
+		// Rolling back to the savepoint already reverts its settings
+		_ = tx.Rollback(ctx)
+		hooks.rolledBack(ctx, cfg)
+		return err
+	}
+	if err = tx.Commit(ctx); err == nil && restore != nil {
+		err = restore(ctx, outer)
+	}
 	if err != nil {
+		hooks.rolledBack(ctx, cfg)
 		return err
 	}
-	return tx.Commit(ctx)
+	hooks.committed(ctx, parent, cfg)
+	return nil
+}
+
+func (r *pgxPoolWrapper) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	if r.pool == nil {
+		return nil, ErrAcquireNotSupported
+	}
+	return r.pool.Acquire(ctx)
 }
 
 func (r *pgxPoolWrapper) GetConnectionFromCtx(ctx context.Context) PgxConnection {
 	conn, ok := ctx.Value(PgxConnectionCtxKey{}).(PgxConnection)
 	if !ok {
-		return r.pool
+		return r.conn
 	}
 	return conn
 }
+
+func findTxAttemptTracer(tracer pgx.QueryTracer) TxAttemptTracer {
+	if multi, ok := tracer.(*multitracer.Tracer); ok {
+		var tracers txAttemptTracers
+		for _, t := range multi.QueryTracers {
+			if attemptTracer, ok := t.(TxAttemptTracer); ok {
+				tracers = append(tracers, attemptTracer)
+			}
+		}
+		if len(tracers) == 0 {
+			return nil
+		}
+		return tracers
+	}
+	attemptTracer, _ := tracer.(TxAttemptTracer)
+	return attemptTracer
+}
+
+type txAttemptTracers []TxAttemptTracer
+
+func (t txAttemptTracers) TraceTxAttempt(ctx context.Context, attempt int, err error) {
+	for _, tracer := range t {
+		tracer.TraceTxAttempt(ctx, attempt, err)
+	}
+}
@@ -6,61 +6,60 @@ import (
 	"github.com/IndexStorm/common-go/config"
 	"github.com/IndexStorm/common-go/telemetry"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
 	"time"
 )
 
+// NewPgxPoolWithOtel opens a pool whose statements are traced with the db attributes of dbConfig.
 func NewPgxPoolWithOtel(
 	ctx context.Context,
 	dbConfig config.Database,
+	opts ...PoolOption,
 ) (*pgxpool.Pool, error) {
-	database, err := NewPgxConnection(ctx,
-		fmt.Sprintf(
-			"postgresql://%s:%s@%s/%s?sslmode=%s",
-			dbConfig.Username,
-			dbConfig.Password,
-			dbConfig.Host,
-			dbConfig.Database,
-			dbConfig.SSLMode,
-		),
-		telemetry.NewPgxTracer(
-			semconv.DBSystemNamePostgreSQL,
-			semconv.DBNamespace(dbConfig.Host+"/"+dbConfig.Database),
-			// semconv.ServerAddress(config.Host),
-			// semconv.ServerPort(int(config.Port)),
-			// semconv.UserName(config.User),
-			// semconv.DBNamespace(config.Database),
-		),
-		nil,
-		time.Second*10)
+	attrs := otelAttributes(dbConfig)
+	tracer := telemetry.NewPgxTracer(attrs...)
+	return NewPgxPool(ctx, dbConfig, append([]PoolOption{PoolWithTracer(tracer)}, opts...)...)
+}
+
+// NewPgxPoolWithOtelMetrics is NewPgxPoolWithOtel that also exports the pool statistics through the
+// global meter provider. Unregister the returned registration before closing the pool.
+func NewPgxPoolWithOtelMetrics(
+	ctx context.Context,
+	dbConfig config.Database,
+	opts ...PoolOption,
+) (*pgxpool.Pool, metric.Registration, error) {
+	database, err := NewPgxPoolWithOtel(ctx, dbConfig, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("open connection: %w", err)
+		return nil, nil, err
 	}
-	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
-	defer cancel()
-	if err = database.Ping(ctx); err != nil {
+	registration, err := telemetry.RegisterPgxPoolMetrics(database, otelAttributes(dbConfig)...)
+	if err != nil {
 		database.Close()
-		return nil, fmt.Errorf("ping database: %w", err)
+		return nil, nil, fmt.Errorf("register pool metrics: %w", err)
+	}
+	return database, registration, nil
+}
+
+func otelAttributes(dbConfig config.Database) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.DBSystemNamePostgreSQL,
+		semconv.DBNamespace(dbConfig.Host + "/" + dbConfig.Database),
+		// semconv.ServerAddress(config.Host),
+		// semconv.ServerPort(int(config.Port)),
+		// semconv.UserName(config.User),
+		// semconv.DBNamespace(config.Database),
 	}
-	return database, nil
 }
 
+// NewPgxPool opens a pool for dbConfig and makes sure the database is reachable.
 func NewPgxPool(
 	ctx context.Context,
 	dbConfig config.Database,
+	opts ...PoolOption,
 ) (*pgxpool.Pool, error) {
-	database, err := NewPgxConnection(ctx,
-		fmt.Sprintf(
-			"postgresql://%s:%s@%s/%s?sslmode=%s",
-			dbConfig.Username,
-			dbConfig.Password,
-			dbConfig.Host,
-			dbConfig.Database,
-			dbConfig.SSLMode,
-		),
-		nil,
-		nil,
-		time.Second*10)
+	database, err := NewPgxPoolWithOptions(ctx, ConnectionString(dbConfig), opts...)
 	if err != nil {
 		return nil, fmt.Errorf("open connection: %w", err)
 	}
@@ -72,3 +71,14 @@ func NewPgxPool(
 	}
 	return database, nil
 }
+
+func ConnectionString(dbConfig config.Database) string {
+	return fmt.Sprintf(
+		"postgresql://%s:%s@%s/%s?sslmode=%s",
+		dbConfig.Username,
+		dbConfig.Password,
+		dbConfig.Host,
+		dbConfig.Database,
+		dbConfig.SSLMode,
+	)
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"iter"
+	"reflect"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrNotFound = errors.New("pgx: record not found")
+
+// QueryOne returns the first row mapped to T, or ErrNotFound when the query returns nothing.
+// Structs are mapped by column name using the `db` tag, any other T is scanned from a single column.
+// The query joins the transaction carried by ctx, if any. Database errors are returned classified, see ClassifyError.
+func QueryOne[T any](ctx context.Context, pool PgxPoolWrapper, sql string, args ...any) (T, error) {
+	rows, err := pool.GetConnectionFromCtx(ctx).Query(ctx, sql, args...)
+	if err != nil {
+		var zero T
+		return zero, ClassifyError(err)
+	}
+	value, err := pgx.CollectOneRow(rows, rowMapper[T]())
+	if errors.Is(err, pgx.ErrNoRows) {
+		return value, ErrNotFound
+	}
+	return value, ClassifyError(err)
+}
+
+// QueryAll returns every row mapped to T, see QueryOne for the mapping rules.
+func QueryAll[T any](ctx context.Context, pool PgxPoolWrapper, sql string, args ...any) ([]T, error) {
+	rows, err := pool.GetConnectionFromCtx(ctx).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, ClassifyError(err)
+	}
+	values, err := pgx.CollectRows(rows, rowMapper[T]())
+	return values, ClassifyError(err)
+}
+
+// QueryIter streams rows mapped to T without buffering the whole result.
+// Iteration stops after the first error, breaking out of the loop releases the rows.
+func QueryIter[T any](ctx context.Context, pool PgxPoolWrapper, sql string, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		rows, err := pool.GetConnectionFromCtx(ctx).Query(ctx, sql, args...)
+		if err != nil {
+			yield(zero, ClassifyError(err))
+			return
+		}
+		defer rows.Close()
+		mapper := rowMapper[T]()
+		for rows.Next() {
+			value, err := mapper(rows)
+			if !yield(value, ClassifyError(err)) || err != nil {
+				return
+			}
+		}
+		if err = rows.Err(); err != nil {
+			yield(zero, ClassifyError(err))
+		}
+	}
+}
+
+var (
+	scannerType = reflect.TypeFor[sql.Scanner]()
+	timeType    = reflect.TypeFor[time.Time]()
+)
+
+func rowMapper[T any]() pgx.RowToFunc[T] {
+	t := reflect.TypeFor[T]()
+	if t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(scannerType) {
+		return pgx.RowToStructByName[T]
+	}
+	return pgx.RowTo[T]
+}
@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IndexStorm/common-go/termination"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	DefaultReplicaHealthCheckInterval = time.Second * 5
+	DefaultReplicaHealthCheckTimeout  = time.Second
+)
+
+// replicaLagQuery reports zero lag when the replica has replayed everything it received,
+// otherwise an idle primary would make a fully caught-up replica look lagging. A replica that lost
+// its upstream has replayed everything too, so it must also be streaming to be considered healthy.
+const replicaLagQuery = `SELECT
+	EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE status = 'streaming'),
+	CASE
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END`
+
+var errReplicaNotStreaming = errors.New("replica is not streaming from its upstream")
+
+type ReplicaConfig struct {
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+	// MaxLag ejects replicas whose replay lag exceeds it or that are not streaming from their upstream,
+	// zero disables the lag check. The check reads pg_stat_wal_receiver, whose status is only visible
+	// to roles with pg_read_all_stats, e.g. members of pg_monitor.
+	MaxLag time.Duration
+}
+
+type ReplicatedPgxPoolWrapper interface {
+	PgxPoolWrapper
+	termination.Shutdowner
+}
+
+type readOnlyCtxKey struct{}
+
+// ContextWithReadOnly marks ctx as tolerant to replication lag, so the replicated wrapper
+// serves it from a replica whenever it is not already inside a transaction.
+func ContextWithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyCtxKey{}, true)
+}
+
+func isReadOnlyContext(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyCtxKey{}).(bool)
+	return readOnly
+}
+
+type replica struct {
+	pool    *pgxpool.Pool
+	wrapper PgxPoolWrapper
+}
+
+type replicatedPoolWrapper struct {
+	primary   PgxPoolWrapper
+	pool      *pgxpool.Pool
+	replicas  []replica
+	healthy   atomic.Pointer[[]replica]
+	next      atomic.Uint64
+	config    ReplicaConfig
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewPgxReplicatedPoolWrapper routes read-only transactions (pgx.ReadOnly access mode) and
+// contexts marked with ContextWithReadOnly to healthy replicas in round-robin order.
+// Writes and everything running inside a transaction stay on that transaction's pool, the primary for writes.
+// When no replica is healthy reads fall back to the primary, as they do until the first health check
+// completes. The wrapper does not close the pools.
+func NewPgxReplicatedPoolWrapper(
+	primary *pgxpool.Pool, replicas []*pgxpool.Pool, cfg ReplicaConfig,
+) ReplicatedPgxPoolWrapper {
+	if cfg.HealthCheckInterval <= 0 {
+		cfg.HealthCheckInterval = DefaultReplicaHealthCheckInterval
+	}
+	if cfg.HealthCheckTimeout <= 0 {
+		cfg.HealthCheckTimeout = DefaultReplicaHealthCheckTimeout
+	}
+	w := &replicatedPoolWrapper{
+		primary: NewPgxPoolWrapper(primary),
+		pool:    primary,
+		config:  cfg,
+		done:    make(chan struct{}),
+	}
+	for _, pool := range replicas {
+		w.replicas = append(w.replicas, replica{pool: pool, wrapper: NewPgxPoolWrapper(pool)})
+	}
+	w.healthy.Store(&[]replica{})
+	go w.healthCheckLoop()
+	return w
+}
+
+func (w *replicatedPoolWrapper) RunInTx(ctx context.Context, fn func(context.Context) error, opts ...TxOption) error {
+	return w.RunInTxWithOptions(ctx, pgx.TxOptions{}, fn, opts...)
+}
+
+func (w *replicatedPoolWrapper) RunInTxWithOptions(
+	ctx context.Context, opts pgx.TxOptions, fn func(context.Context) error, txOpts ...TxOption,
+) error {
+	if isReadOnlyContext(ctx) {
+		opts.AccessMode = pgx.ReadOnly
+	}
+	if opts.AccessMode == pgx.ReadOnly {
+		if r, ok := w.pickReplica(); ok {
+			return r.wrapper.RunInTxWithOptions(ctx, opts, fn, txOpts...)
+		}
+	}
+	return w.primary.RunInTxWithOptions(ctx, opts, fn, txOpts...)
+}
+
+func (w *replicatedPoolWrapper) GetConnectionFromCtx(ctx context.Context) PgxConnection {
+	if conn, ok := ctx.Value(PgxConnectionCtxKey{}).(PgxConnection); ok {
+		return conn
+	}
+	if isReadOnlyContext(ctx) {
+		if r, ok := w.pickReplica(); ok {
+			return r.pool
+		}
+	}
+	return w.pool
+}
+
+// Acquire pins a connection to the primary, session state must never end up on a replica.
+func (w *replicatedPoolWrapper) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	return w.pool.Acquire(ctx)
+}
+
+func (w *replicatedPoolWrapper) Shutdown(ctx context.Context) error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+	return nil
+}
+
+func (w *replicatedPoolWrapper) pickReplica() (replica, bool) {
+	healthy := *w.healthy.Load()
+	if len(healthy) == 0 {
+		return replica{}, false
+	}
+	return healthy[w.next.Add(1)%uint64(len(healthy))], true
+}
+
+func (w *replicatedPoolWrapper) healthCheckLoop() {
+	ticker := time.NewTicker(w.config.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		w.checkReplicas()
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *replicatedPoolWrapper) checkReplicas() {
+	healthy := make([]replica, 0, len(w.replicas))
+	for _, r := range w.replicas {
+		if err := w.checkReplica(r.pool); err == nil {
+			healthy = append(healthy, r)
+		}
+	}
+	w.healthy.Store(&healthy)
+}
+
+func (w *replicatedPoolWrapper) checkReplica(pool *pgxpool.Pool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), w.config.HealthCheckTimeout)
+	defer cancel()
+	if w.config.MaxLag <= 0 {
+		return pool.Ping(ctx)
+	}
+	var streaming bool
+	var lagSeconds float64
+	if err := pool.QueryRow(ctx, replicaLagQuery).Scan(&streaming, &lagSeconds); err != nil {
+		return fmt.Errorf("query replica lag: %w", err)
+	}
+	if !streaming {
+		return errReplicaNotStreaming
+	}
+	if lag := time.Duration(lagSeconds * float64(time.Second)); lag > w.config.MaxLag {
+		return fmt.Errorf("replica lag %s exceeds %s", lag, w.config.MaxLag)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+const schemaDir = "testdata/migrations"
+
+// newReplicatedWrapper serves the "replica" from the same database, told apart by application_name.
+func newReplicatedWrapper(t *testing.T, cfg db.ReplicaConfig) db.ReplicatedPgxPoolWrapper {
+	primary, _ := dbtest.New(t, schemaDir)
+	replicaConfig := primary.Config()
+	replicaConfig.ConnConfig.RuntimeParams["application_name"] = "replica"
+	replica, err := pgxpool.NewWithConfig(context.Background(), replicaConfig)
+	require.NoError(t, err)
+	t.Cleanup(replica.Close)
+	cfg.HealthCheckInterval = time.Hour
+	wrapper := db.NewPgxReplicatedPoolWrapper(primary, []*pgxpool.Pool{replica}, cfg)
+	t.Cleanup(func() {
+		require.NoError(t, wrapper.Shutdown(context.Background()))
+	})
+	return wrapper
+}
+
+func applicationName(t *testing.T, ctx context.Context, wrapper db.PgxPoolWrapper) string {
+	var name string
+	err := wrapper.RunInTx(ctx, func(ctx context.Context) error {
+		return wrapper.GetConnectionFromCtx(ctx).QueryRow(ctx, "SELECT current_setting('application_name')").Scan(&name)
+	})
+	require.NoError(t, err)
+	return name
+}
+
+func TestReplicatedPoolWrapper_RoutesReadsToReplica(t *testing.T) {
+	wrapper := newReplicatedWrapper(t, db.ReplicaConfig{})
+	db.CheckReplicas(wrapper)
+	ctx := context.Background()
+
+	require.Equal(t, "replica", applicationName(t, db.ContextWithReadOnly(ctx), wrapper))
+	require.NotEqual(t, "replica", applicationName(t, ctx, wrapper))
+
+	var name string
+	err := wrapper.GetConnectionFromCtx(db.ContextWithReadOnly(ctx)).
+		QueryRow(ctx, "SELECT current_setting('application_name')").Scan(&name)
+	require.NoError(t, err)
+	require.Equal(t, "replica", name)
+}
+
+func TestReplicatedPoolWrapper_FallsBackToPrimary(t *testing.T) {
+	// The replica is not streaming from any upstream, so the lag check ejects it
+	wrapper := newReplicatedWrapper(t, db.ReplicaConfig{MaxLag: time.Minute})
+	ctx := db.ContextWithReadOnly(context.Background())
+	require.NotEqual(t, "replica", applicationName(t, ctx, wrapper))
+
+	db.CheckReplicas(wrapper)
+	require.NotEqual(t, "replica", applicationName(t, ctx, wrapper))
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/IndexStorm/common-go/db/pgerr"
+)
+
+// RetryPolicy re-runs a whole transaction when it fails with one of RetryableKinds.
+// Backoff grows exponentially from InitialBackoff up to MaxBackoff with full jitter.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// RetryableKinds are pgerr sentinels, such as pgerr.ErrSerialization.
+	RetryableKinds []error
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     time.Second,
+	Multiplier:     2,
+	RetryableKinds: []error{pgerr.ErrSerialization, pgerr.ErrDeadlock},
+}
+
+// TxAttemptTracer is implemented by query tracers that want to observe retried transactions.
+type TxAttemptTracer interface {
+	TraceTxAttempt(ctx context.Context, attempt int, err error)
+}
+
+// WithRetry retries transactions begun by this call. Transactions joined or nested
+// into an outer one are never retried on their own, the outermost call owns the retry.
+func WithRetry(policy RetryPolicy) TxOption {
+	return &txRetryOption{policy: policy}
+}
+
+type txRetryOption struct {
+	policy RetryPolicy
+}
+
+func (o *txRetryOption) apply(cfg *txConfig) {
+	cfg.retry = &o.policy
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	kind := pgerr.Kind(err)
+	return kind != nil && slices.Contains(p.RetryableKinds, kind)
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff)
+	for range attempt - 1 {
+		backoff *= max(p.Multiplier, 1)
+		if p.MaxBackoff > 0 && backoff >= float64(p.MaxBackoff) {
+			backoff = float64(p.MaxBackoff)
+			break
+		}
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(backoff) + 1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
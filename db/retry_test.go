@@ -0,0 +1,135 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/IndexStorm/common-go/db/pgerr"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+type attempt struct {
+	number int
+	err    error
+}
+
+type attemptTracer struct {
+	attempts []attempt
+}
+
+func (t *attemptTracer) TraceTxAttempt(_ context.Context, number int, err error) {
+	t.attempts = append(t.attempts, attempt{number: number, err: err})
+}
+
+func newRetryFake(t *testing.T) (*dbtest.Fake, *attemptTracer) {
+	fake := dbtest.NewFake(t)
+	tracer := &attemptTracer{}
+	db.SetTxAttemptTracer(fake.PgxPoolWrapper, tracer)
+	return fake, tracer
+}
+
+var noBackoffPolicy = db.RetryPolicy{
+	MaxAttempts:    3,
+	RetryableKinds: []error{pgerr.ErrSerialization, pgerr.ErrDeadlock},
+}
+
+func TestRunInTx_RetriesSerializationFailure(t *testing.T) {
+	fake, tracer := newRetryFake(t)
+	serialization := &pgconn.PgError{Code: "40001"}
+	fake.FailNextCommit(serialization)
+
+	runs := 0
+	err := fake.RunInTx(context.Background(), func(context.Context) error {
+		runs++
+		return nil
+	}, db.WithRetry(noBackoffPolicy))
+	require.NoError(t, err)
+	require.Equal(t, 2, runs)
+	require.Equal(t, 1, fake.Commits())
+	require.Equal(t, 1, fake.Rollbacks())
+	require.Len(t, tracer.attempts, 2)
+	require.Equal(t, 1, tracer.attempts[0].number)
+	require.ErrorIs(t, tracer.attempts[0].err, serialization)
+	require.Equal(t, attempt{number: 2}, tracer.attempts[1])
+}
+
+func TestRunInTx_RetryGivesUpAfterMaxAttempts(t *testing.T) {
+	fake, tracer := newRetryFake(t)
+	for range noBackoffPolicy.MaxAttempts {
+		fake.FailNextCommit(&pgconn.PgError{Code: "40P01"})
+	}
+
+	err := fake.RunInTx(context.Background(), func(context.Context) error {
+		return nil
+	}, db.WithRetry(noBackoffPolicy))
+	require.ErrorIs(t, err, db.ErrDeadlock)
+	require.Len(t, tracer.attempts, noBackoffPolicy.MaxAttempts)
+	require.Equal(t, 0, fake.Commits())
+}
+
+func TestRunInTx_DoesNotRetryOtherErrors(t *testing.T) {
+	fake, tracer := newRetryFake(t)
+	unique := &pgconn.PgError{Code: "23505"}
+
+	runs := 0
+	err := fake.RunInTx(context.Background(), func(context.Context) error {
+		runs++
+		return unique
+	}, db.WithRetry(noBackoffPolicy))
+	require.ErrorIs(t, err, unique)
+	require.ErrorIs(t, err, db.ErrUniqueViolation)
+	require.Equal(t, 1, runs)
+	require.Len(t, tracer.attempts, 1)
+}
+
+func TestRunInTx_NestedCallsAreNotRetried(t *testing.T) {
+	fake, tracer := newRetryFake(t)
+	serialization := &pgconn.PgError{Code: "40001"}
+
+	runs := 0
+	err := fake.RunInTx(context.Background(), func(ctx context.Context) error {
+		return fake.RunInTx(ctx, func(context.Context) error {
+			runs++
+			return serialization
+		}, db.WithRetry(noBackoffPolicy))
+	})
+	require.ErrorIs(t, err, serialization)
+	require.Equal(t, 1, runs)
+	require.Empty(t, tracer.attempts)
+}
+
+func TestRunInTx_RetryStopsWhenContextIsDone(t *testing.T) {
+	fake, _ := newRetryFake(t)
+	fake.FailNextCommit(&pgconn.PgError{Code: "40001"})
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := noBackoffPolicy
+	policy.InitialBackoff = time.Hour
+
+	err := fake.RunInTx(ctx, func(context.Context) error {
+		cancel()
+		return nil
+	}, db.WithRetry(policy))
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorIs(t, err, db.ErrSerialization)
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := db.RetryPolicy{InitialBackoff: time.Millisecond * 10, MaxBackoff: time.Millisecond * 50, Multiplier: 2}
+	for range 100 {
+		require.LessOrEqual(t, policy.Backoff(1), time.Millisecond*10)
+		require.LessOrEqual(t, policy.Backoff(3), time.Millisecond*40)
+		require.LessOrEqual(t, policy.Backoff(10), time.Millisecond*50)
+		require.GreaterOrEqual(t, policy.Backoff(10), time.Duration(0))
+	}
+	require.Zero(t, (&db.RetryPolicy{MaxBackoff: time.Second}).Backoff(5))
+}
+
+func TestNewPgxPoolWrapper_NilPool(t *testing.T) {
+	require.NotPanics(t, func() {
+		db.NewPgxPoolWrapper(nil)
+	})
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SessionVariable maps a value carried by the context to a Postgres setting read by RLS policies,
+// e.g. current_setting('app.user_id', true).
+type SessionVariable struct {
+	Name string
+	// Value returns the setting value, false leaves the setting unset.
+	Value func(ctx context.Context) (string, bool)
+}
+
+// ContextVariable maps the context value stored under key to the setting name. Strings, string slices
+// (joined with commas, for use with string_to_array) and fmt.Stringer values are supported.
+func ContextVariable(name string, key any) SessionVariable {
+	return SessionVariable{Name: name, Value: func(ctx context.Context) (string, bool) {
+		switch value := ctx.Value(key).(type) {
+		case string:
+			return value, value != ""
+		case []string:
+			return strings.Join(value, ","), len(value) > 0
+		case fmt.Stringer:
+			return value.String(), true
+		default:
+			return "", false
+		}
+	}}
+}
+
+type RowLevelSecurityConfig struct {
+	Variables []SessionVariable
+	// RequireTx makes every statement issued outside a transaction fail with ErrNotInTx,
+	// such a statement would run without the variables the policies depend on.
+	RequireTx bool
+}
+
+type rowLevelSecurityWrapper struct {
+	PgxPoolWrapper
+	config RowLevelSecurityConfig
+}
+
+// NewRowLevelSecurityWrapper sets cfg.Variables locally at the start of every transaction and savepoint
+// begun by RunInTx, in the same round trip as TxSettings. A transaction joined with PropagationRequired
+// keeps the variables of the one that began it.
+func NewRowLevelSecurityWrapper(pool PgxPoolWrapper, cfg RowLevelSecurityConfig) PgxPoolWrapper {
+	return &rowLevelSecurityWrapper{PgxPoolWrapper: pool, config: cfg}
+}
+
+func (w *rowLevelSecurityWrapper) RunInTx(ctx context.Context, fn func(context.Context) error, opts ...TxOption) error {
+	return w.RunInTxWithOptions(ctx, pgx.TxOptions{}, fn, opts...)
+}
+
+func (w *rowLevelSecurityWrapper) RunInTxWithOptions(
+	ctx context.Context, opts pgx.TxOptions, fn func(context.Context) error, txOpts ...TxOption,
+) error {
+	params := make(map[string]string, len(w.config.Variables))
+	for _, variable := range w.config.Variables {
+		if value, ok := variable.Value(ctx); ok {
+			params[variable.Name] = value
+		}
+	}
+	// Variables come first, so explicit settings of the call may still override them
+	txOpts = append([]TxOption{WithTxSettings(TxSettings{Parameters: params})}, txOpts...)
+	return w.PgxPoolWrapper.RunInTxWithOptions(ctx, opts, fn, txOpts...)
+}
+
+func (w *rowLevelSecurityWrapper) GetConnectionFromCtx(ctx context.Context) PgxConnection {
+	if _, ok := ctx.Value(PgxConnectionCtxKey{}).(pgx.Tx); !ok && w.config.RequireTx {
+		return errConnection{err: ErrNotInTx}
+	}
+	return w.PgxPoolWrapper.GetConnectionFromCtx(ctx)
+}
+
+func (w *rowLevelSecurityWrapper) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	acquirer, ok := w.PgxPoolWrapper.(ConnAcquirer)
+	if !ok {
+		return nil, ErrAcquireNotSupported
+	}
+	return acquirer.Acquire(ctx)
+}
+
+// errConnection fails every statement with err.
+type errConnection struct {
+	err error
+}
+
+func (c errConnection) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, c.err
+}
+
+func (c errConnection) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, c.err
+}
+
+func (c errConnection) QueryRow(context.Context, string, ...any) pgx.Row {
+	return errRow{err: c.err}
+}
+
+func (c errConnection) CopyFrom(context.Context, pgx.Identifier, []string, pgx.CopyFromSource) (int64, error) {
+	return 0, c.err
+}
+
+func (c errConnection) SendBatch(context.Context, *pgx.Batch) pgx.BatchResults {
+	return errBatchResults{err: c.err}
+}
+
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...any) error {
+	return r.err
+}
+
+type errBatchResults struct {
+	err error
+}
+
+func (b errBatchResults) Exec() (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, b.err
+}
+
+func (b errBatchResults) Query() (pgx.Rows, error) {
+	return nil, b.err
+}
+
+func (b errBatchResults) QueryRow() pgx.Row {
+	return errRow{err: b.err}
+}
+
+func (b errBatchResults) Close() error {
+	return b.err
+}
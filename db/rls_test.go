@@ -0,0 +1,39 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	tenantCtxKey struct{}
+	rolesCtxKey  struct{}
+)
+
+func TestRowLevelSecurityWrapper(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	pool := db.NewRowLevelSecurityWrapper(fake, db.RowLevelSecurityConfig{
+		Variables: []db.SessionVariable{
+			db.ContextVariable("app.tenant_id", tenantCtxKey{}),
+			db.ContextVariable("app.roles", rolesCtxKey{}),
+		},
+		RequireTx: true,
+	})
+	ctx := context.WithValue(context.Background(), tenantCtxKey{}, "t1")
+
+	_, err := pool.GetConnectionFromCtx(ctx).Exec(ctx, "DELETE FROM orders")
+	require.ErrorIs(t, err, db.ErrNotInTx)
+
+	fake.Expect(dbtest.Exact("SELECT set_config($1, $2, true)")).WithArgs("app.tenant_id", "t1")
+	fake.Expect(dbtest.Exact("DELETE FROM orders")).WillReturnTag("DELETE 2")
+	err = pool.RunInTx(ctx, func(ctx context.Context) error {
+		tag, err := pool.GetConnectionFromCtx(ctx).Exec(ctx, "DELETE FROM orders")
+		require.EqualValues(t, 2, tag.RowsAffected())
+		return err
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TxSettings are run-time parameters applied with SET LOCAL semantics at the start of every
+// transaction or savepoint begun by RunInTx, so they never leak to other users of the connection.
+// When ctx has a deadline, statement_timeout is set to the time left until it, so a slow query
+// cannot outlive the request it serves.
+type TxSettings struct {
+	// IgnoreDeadline leaves statement_timeout to the server value, or to Parameters, whatever the deadline of ctx.
+	IgnoreDeadline bool
+	// IdleInTransactionTimeout sets idle_in_transaction_session_timeout, zero leaves the server value.
+	IdleInTransactionTimeout time.Duration
+	// Parameters maps parameter names to values, e.g. "lock_timeout" to "5s".
+	Parameters map[string]string
+}
+
+type txSettingsCtxKey struct{}
+
+// ContextWithTxSettings attaches settings to ctx for every RunInTx called with it,
+// merged into the settings already attached. Settings passed with WithTxSettings take precedence.
+func ContextWithTxSettings(ctx context.Context, settings TxSettings) context.Context {
+	if current, ok := ctx.Value(txSettingsCtxKey{}).(TxSettings); ok {
+		settings = current.merge(settings)
+	}
+	return context.WithValue(ctx, txSettingsCtxKey{}, settings)
+}
+
+// WithTxSettings applies settings to this call only, on top of the ones attached to the context.
+func WithTxSettings(settings TxSettings) TxOption {
+	return &txSettingsOption{settings: settings}
+}
+
+type txSettingsOption struct {
+	settings TxSettings
+}
+
+func (o *txSettingsOption) apply(cfg *txConfig) {
+	if cfg.settings != nil {
+		merged := cfg.settings.merge(o.settings)
+		cfg.settings = &merged
+		return
+	}
+	cfg.settings = &o.settings
+}
+
+func (s TxSettings) merge(other TxSettings) TxSettings {
+	merged := TxSettings{
+		IgnoreDeadline:           s.IgnoreDeadline || other.IgnoreDeadline,
+		IdleInTransactionTimeout: s.IdleInTransactionTimeout,
+		Parameters:               make(map[string]string, len(s.Parameters)+len(other.Parameters)),
+	}
+	if other.IdleInTransactionTimeout > 0 {
+		merged.IdleInTransactionTimeout = other.IdleInTransactionTimeout
+	}
+	maps.Copy(merged.Parameters, s.Parameters)
+	maps.Copy(merged.Parameters, other.Parameters)
+	return merged
+}
+
+// parameters resolves the values to set, in a stable order.
+func (s TxSettings) parameters(ctx context.Context) ([]string, error) {
+	params := make(map[string]string, len(s.Parameters)+2)
+	maps.Copy(params, s.Parameters)
+	if s.IdleInTransactionTimeout > 0 {
+		params["idle_in_transaction_session_timeout"] = strconv.FormatInt(s.IdleInTransactionTimeout.Milliseconds(), 10)
+	}
+	if deadline, ok := ctx.Deadline(); ok && !s.IgnoreDeadline {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+		// Zero would disable the timeout altogether
+		params["statement_timeout"] = strconv.FormatInt(max(left.Milliseconds(), 1), 10)
+	}
+	names := slices.Sorted(maps.Keys(params))
+	pairs := make([]string, 0, len(names)*2)
+	for _, name := range names {
+		pairs = append(pairs, name, params[name])
+	}
+	return pairs, nil
+}
+
+// restoreTxSettings puts back the values a released savepoint overrode. Postgres reverts them on
+// ROLLBACK TO SAVEPOINT, but RELEASE SAVEPOINT keeps them for the rest of the outer transaction.
+type restoreTxSettings func(ctx context.Context, outer pgx.Tx) error
+
+// applyTxSettings sets the parameters of the context and cfg in a single round trip. Within a savepoint
+// the current values are read first, the returned func restores them in the outer transaction.
+func applyTxSettings(ctx context.Context, tx pgx.Tx, savepoint bool, cfg txConfig) (restoreTxSettings, error) {
+	settings, _ := ctx.Value(txSettingsCtxKey{}).(TxSettings)
+	if cfg.settings != nil {
+		settings = settings.merge(*cfg.settings)
+	}
+	pairs, err := settings.parameters(ctx)
+	if err != nil || len(pairs) == 0 {
+		return nil, err
+	}
+	var restore restoreTxSettings
+	if savepoint {
+		previous, err := currentSettings(ctx, tx, pairs)
+		if err != nil {
+			return nil, err
+		}
+		restore = func(ctx context.Context, outer pgx.Tx) error {
+			if err := setConfig(ctx, outer, previous); err != nil {
+				return fmt.Errorf("restore settings: %w", err)
+			}
+			return nil
+		}
+	}
+	if err = setConfig(ctx, tx, pairs); err != nil {
+		return nil, fmt.Errorf("apply settings: %w", err)
+	}
+	return restore, nil
+}
+
+// currentSettings returns the values of the parameters named in pairs, in the same layout.
+// Custom parameters never set in the session read as empty, like after a transaction that set them.
+func currentSettings(ctx context.Context, tx pgx.Tx, pairs []string) ([]string, error) {
+	names := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		names = append(names, pairs[i])
+	}
+	rows, err := tx.Query(ctx, "SELECT name, COALESCE(current_setting(name, true), '') FROM unnest($1::text[]) AS name", names)
+	if err != nil {
+		return nil, fmt.Errorf("read settings: %w", err)
+	}
+	previous, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) ([]string, error) {
+		var name, value string
+		err := row.Scan(&name, &value)
+		return []string{name, value}, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read settings: %w", err)
+	}
+	return slices.Concat(previous...), nil
+}
+
+func setConfig(ctx context.Context, conn PgxConnection, pairs []string) error {
+	calls := make([]string, 0, len(pairs)/2)
+	args := make([]any, len(pairs))
+	for i := 0; i < len(pairs); i += 2 {
+		calls = append(calls, fmt.Sprintf("set_config($%d, $%d, true)", i+1, i+2))
+		args[i], args[i+1] = pairs[i], pairs[i+1]
+	}
+	_, err := conn.Exec(ctx, "SELECT "+strings.Join(calls, ", "), args...)
+	return err
+}
@@ -0,0 +1,127 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunInTx_AppliesSettings(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctx = db.ContextWithTxSettings(ctx, db.TxSettings{Parameters: map[string]string{"lock_timeout": "1s"}})
+
+	var timeout string
+	fake.Expect(dbtest.Exact("SELECT set_config($1, $2, true), set_config($3, $4, true), set_config($5, $6, true)")).WithArgs(
+		"idle_in_transaction_session_timeout", "5000",
+		"lock_timeout", "2s",
+		"statement_timeout", dbtest.AnyArg,
+	)
+	err := fake.RunInTx(ctx, func(ctx context.Context) error {
+		statements := fake.Statements()
+		timeout = statements[len(statements)-1].Args[5].(string)
+		return nil
+	}, db.WithTxSettings(db.TxSettings{
+		IdleInTransactionTimeout: time.Second * 5,
+		Parameters:               map[string]string{"lock_timeout": "2s"},
+	}))
+	require.NoError(t, err)
+	ms, err := strconv.Atoi(timeout)
+	require.NoError(t, err)
+	require.InDelta(t, time.Minute.Milliseconds(), ms, 1000)
+}
+
+func TestRunInTx_StatementTimeoutFollowsDeadline(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	fake.Expect(dbtest.Exact("SELECT set_config($1, $2, true)")).WithArgs("statement_timeout", dbtest.AnyArg)
+
+	err := fake.RunInTx(ctx, func(ctx context.Context) error {
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"BEGIN", "SELECT set_config($1, $2, true)", "COMMIT"}, statementSQL(fake))
+}
+
+func TestRunInTx_SettingsWithoutDeadline(t *testing.T) {
+	fake := dbtest.NewFake(t)
+
+	err := fake.RunInTx(context.Background(), func(ctx context.Context) error {
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"BEGIN", "COMMIT"}, statementSQL(fake))
+}
+
+func TestRunInTx_IgnoreDeadline(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	ctx = db.ContextWithTxSettings(ctx, db.TxSettings{IgnoreDeadline: true})
+
+	err := fake.RunInTx(ctx, func(ctx context.Context) error {
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"BEGIN", "COMMIT"}, statementSQL(fake))
+}
+
+func TestRunInTx_RestoresSettingsOfSavepoints(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	ctx := context.Background()
+	lockTimeout := func(value string) db.TxOption {
+		return db.WithTxSettings(db.TxSettings{Parameters: map[string]string{"lock_timeout": value}})
+	}
+	setLockTimeout := func(value string) {
+		fake.Expect(dbtest.Exact("SELECT set_config($1, $2, true)")).WithArgs("lock_timeout", value)
+	}
+	readLockTimeout := func(value string) {
+		fake.Expect(dbtest.Regex(`current_setting`)).WithArgs([]string{"lock_timeout"}).
+			WillReturnRows([]string{"name", "value"}, []any{"lock_timeout", value})
+	}
+	setLockTimeout("1s")
+	// Released savepoint
+	readLockTimeout("1s")
+	setLockTimeout("5s")
+	setLockTimeout("1s")
+	// Rolled back savepoint, the rollback reverts its settings
+	readLockTimeout("1s")
+	setLockTimeout("10s")
+
+	boom := errors.New("boom")
+	err := fake.RunInTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, fake.RunInTx(ctx, func(context.Context) error {
+			return nil
+		}, lockTimeout("5s")))
+		err := fake.RunInTx(ctx, func(context.Context) error {
+			return boom
+		}, lockTimeout("10s"))
+		require.ErrorIs(t, err, boom)
+		return nil
+	}, lockTimeout("1s"))
+	require.NoError(t, err)
+
+	sql := statementSQL(fake)
+	require.Equal(t, []string{
+		"BEGIN",
+		"SELECT set_config($1, $2, true)",
+		"SAVEPOINT sp_1",
+		sql[3],
+		"SELECT set_config($1, $2, true)",
+		"RELEASE SAVEPOINT sp_1",
+		"SELECT set_config($1, $2, true)",
+		"SAVEPOINT sp_2",
+		sql[8],
+		"SELECT set_config($1, $2, true)",
+		"ROLLBACK TO SAVEPOINT sp_2",
+		"COMMIT",
+	}, sql)
+}
@@ -0,0 +1,259 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const tenantResetTimeout = time.Second * 5
+
+var (
+	ErrTenantRequired = errors.New("pgx: context does not carry a tenant")
+	ErrInvalidSchema  = errors.New("pgx: invalid schema name")
+)
+
+var schemaNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+type TenantSchemaConfig struct {
+	// Tenant returns the tenant the context is served for.
+	Tenant func(ctx context.Context) (string, bool)
+	// Schema maps a tenant to its schema, by default "tenant_" followed by the lowercased tenant
+	// with dashes replaced by underscores. The result must be a lowercase unquoted identifier.
+	Schema func(tenant string) string
+	// SharedSchemas follow the tenant schema in search_path, e.g. "public" for shared tables and extensions.
+	SharedSchemas []string
+	// RequireTenant fails statements and transactions with ErrTenantRequired when ctx carries no tenant,
+	// otherwise they run with the server search_path.
+	RequireTenant bool
+}
+
+type tenantSchemaWrapper struct {
+	PgxPoolWrapper
+	config TenantSchemaConfig
+}
+
+// NewTenantSchemaWrapper routes every statement to the schema of the tenant carried by the context.
+// Transactions begun by RunInTx set search_path locally, like TxSettings. Outside a transaction each
+// statement runs on a connection pinned from pool, whose search_path is reset before it is released,
+// so the path of a tenant never leaks to the next user of the connection.
+func NewTenantSchemaWrapper(pool PgxPoolWrapper, cfg TenantSchemaConfig) PgxPoolWrapper {
+	if cfg.Schema == nil {
+		cfg.Schema = func(tenant string) string {
+			return "tenant_" + strings.ToLower(strings.ReplaceAll(tenant, "-", "_"))
+		}
+	}
+	return &tenantSchemaWrapper{PgxPoolWrapper: pool, config: cfg}
+}
+
+// SanitizeSchema validates schema as a lowercase unquoted identifier and returns it quoted.
+func SanitizeSchema(schema string) (string, error) {
+	if !schemaNamePattern.MatchString(schema) || strings.HasPrefix(schema, "pg_") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidSchema, schema)
+	}
+	return pgx.Identifier{schema}.Sanitize(), nil
+}
+
+// searchPath returns an empty path when ctx carries no tenant and none is required.
+func (w *tenantSchemaWrapper) searchPath(ctx context.Context) (string, error) {
+	tenant, ok := w.config.Tenant(ctx)
+	if !ok || tenant == "" {
+		if w.config.RequireTenant {
+			return "", ErrTenantRequired
+		}
+		return "", nil
+	}
+	schemas := append([]string{w.config.Schema(tenant)}, w.config.SharedSchemas...)
+	quoted := make([]string, len(schemas))
+	for i, schema := range schemas {
+		var err error
+		if quoted[i], err = SanitizeSchema(schema); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(quoted, ", "), nil
+}
+
+func (w *tenantSchemaWrapper) RunInTx(ctx context.Context, fn func(context.Context) error, opts ...TxOption) error {
+	return w.RunInTxWithOptions(ctx, pgx.TxOptions{}, fn, opts...)
+}
+
+func (w *tenantSchemaWrapper) RunInTxWithOptions(
+	ctx context.Context, opts pgx.TxOptions, fn func(context.Context) error, txOpts ...TxOption,
+) error {
+	path, err := w.searchPath(ctx)
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		txOpts = append([]TxOption{WithTxSettings(TxSettings{Parameters: map[string]string{"search_path": path}})}, txOpts...)
+	}
+	return w.PgxPoolWrapper.RunInTxWithOptions(ctx, opts, fn, txOpts...)
+}
+
+func (w *tenantSchemaWrapper) GetConnectionFromCtx(ctx context.Context) PgxConnection {
+	if _, ok := ctx.Value(PgxConnectionCtxKey{}).(pgx.Tx); ok {
+		return w.PgxPoolWrapper.GetConnectionFromCtx(ctx)
+	}
+	path, err := w.searchPath(ctx)
+	if err != nil {
+		return errConnection{err: err}
+	}
+	if path == "" {
+		return w.PgxPoolWrapper.GetConnectionFromCtx(ctx)
+	}
+	return &tenantConnection{pool: w.PgxPoolWrapper, searchPath: path}
+}
+
+func (w *tenantSchemaWrapper) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	acquirer, ok := w.PgxPoolWrapper.(ConnAcquirer)
+	if !ok {
+		return nil, ErrAcquireNotSupported
+	}
+	return acquirer.Acquire(ctx)
+}
+
+// tenantConnection pins a connection for every statement and releases it once the result is consumed.
+type tenantConnection struct {
+	pool       PgxPoolWrapper
+	searchPath string
+}
+
+func (c *tenantConnection) acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	conn, err := acquireConn(ctx, c.pool)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = conn.Exec(ctx, "SELECT set_config('search_path', $1, false)", c.searchPath); err != nil {
+		releaseTenantConn(conn)
+		return nil, fmt.Errorf("set search_path: %w", err)
+	}
+	return conn, nil
+}
+
+// releaseTenantConn resets search_path, a connection that cannot be reset is closed instead.
+func releaseTenantConn(conn *pgxpool.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), tenantResetTimeout)
+	defer cancel()
+	if _, err := conn.Exec(ctx, "RESET search_path"); err != nil {
+		_ = conn.Hijack().Close(ctx)
+		return
+	}
+	conn.Release()
+}
+
+func (c *tenantConnection) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	conn, err := c.acquire(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer releaseTenantConn(conn)
+	return conn.Exec(ctx, sql, args...)
+}
+
+func (c *tenantConnection) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	conn, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		releaseTenantConn(conn)
+		return nil, err
+	}
+	return &tenantRows{Rows: rows, conn: conn}, nil
+}
+
+func (c *tenantConnection) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	rows, err := c.Query(ctx, sql, args...)
+	if err != nil {
+		return errRow{err: err}
+	}
+	return &tenantRow{rows: rows}
+}
+
+func (c *tenantConnection) CopyFrom(
+	ctx context.Context, table pgx.Identifier, columns []string, rowSrc pgx.CopyFromSource,
+) (int64, error) {
+	conn, err := c.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer releaseTenantConn(conn)
+	return conn.CopyFrom(ctx, table, columns, rowSrc)
+}
+
+func (c *tenantConnection) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	conn, err := c.acquire(ctx)
+	if err != nil {
+		return errBatchResults{err: err}
+	}
+	return &tenantBatchResults{BatchResults: conn.SendBatch(ctx, b), conn: conn}
+}
+
+// tenantRows releases the connection once the rows are closed or exhausted.
+type tenantRows struct {
+	pgx.Rows
+	conn *pgxpool.Conn
+	once sync.Once
+}
+
+func (r *tenantRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.release()
+	return false
+}
+
+func (r *tenantRows) Close() {
+	r.Rows.Close()
+	r.release()
+}
+
+func (r *tenantRows) release() {
+	r.once.Do(func() {
+		releaseTenantConn(r.conn)
+	})
+}
+
+type tenantRow struct {
+	rows pgx.Rows
+}
+
+func (r *tenantRow) Scan(dest ...any) error {
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+	return r.rows.Err()
+}
+
+type tenantBatchResults struct {
+	pgx.BatchResults
+	conn *pgxpool.Conn
+	once sync.Once
+}
+
+func (b *tenantBatchResults) Close() error {
+	err := b.BatchResults.Close()
+	b.once.Do(func() {
+		releaseTenantConn(b.conn)
+	})
+	return err
+}
@@ -0,0 +1,104 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeSchema(t *testing.T) {
+	quoted, err := db.SanitizeSchema("tenant_42")
+	require.NoError(t, err)
+	require.Equal(t, `"tenant_42"`, quoted)
+
+	for _, schema := range []string{"", "Tenant", `x"; DROP TABLE users; --`, "pg_catalog", "1tenant"} {
+		_, err = db.SanitizeSchema(schema)
+		require.ErrorIs(t, err, db.ErrInvalidSchema, schema)
+	}
+}
+
+func TestTenantSchemaWrapper(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	pool := db.NewTenantSchemaWrapper(fake, db.TenantSchemaConfig{
+		Tenant: func(ctx context.Context) (string, bool) {
+			tenant, ok := ctx.Value(tenantCtxKey{}).(string)
+			return tenant, ok
+		},
+		SharedSchemas: []string{"public"},
+		RequireTenant: true,
+	})
+
+	err := pool.RunInTx(context.Background(), func(ctx context.Context) error {
+		return nil
+	})
+	require.ErrorIs(t, err, db.ErrTenantRequired)
+
+	_, err = pool.GetConnectionFromCtx(context.WithValue(context.Background(), tenantCtxKey{}, "a;b")).
+		Exec(context.Background(), "SELECT 1")
+	require.ErrorIs(t, err, db.ErrInvalidSchema)
+
+	ctx := context.WithValue(context.Background(), tenantCtxKey{}, "Acme-1")
+	// Outside a transaction the statement needs a pinned connection, which the fake cannot provide
+	_, err = db.QueryOne[int64](ctx, pool, "SELECT count(*) FROM orders")
+	require.ErrorIs(t, err, db.ErrAcquireNotSupported)
+
+	fake.Expect(dbtest.Exact("SELECT set_config($1, $2, true)")).WithArgs("search_path", `"tenant_acme_1", "public"`)
+	fake.Expect(dbtest.Exact("SELECT count(*) FROM orders")).WillReturnRows([]string{"count"}, []any{int64(3)})
+	err = pool.RunInTx(ctx, func(ctx context.Context) error {
+		count, err := db.QueryOne[int64](ctx, pool, "SELECT count(*) FROM orders")
+		require.Equal(t, int64(3), count)
+		return err
+	})
+	require.NoError(t, err)
+}
+
+func TestTenantSchemaWrapper_WithoutTenant(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	pool := db.NewTenantSchemaWrapper(fake, db.TenantSchemaConfig{
+		Tenant: func(context.Context) (string, bool) {
+			return "", false
+		},
+	})
+	fake.Expect(dbtest.Exact("SELECT count(*) FROM tenants")).WillReturnRows([]string{"count"}, []any{int64(2)})
+
+	count, err := db.QueryOne[int64](context.Background(), pool, "SELECT count(*) FROM tenants")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+}
+
+func TestTenantSchemaWrapper_ResetsPinnedConnections(t *testing.T) {
+	raw, wrapper := dbtest.New(t, schemaDir)
+	ctx := context.Background()
+	_, err := raw.Exec(ctx, "CREATE SCHEMA tenant_acme_1;"+
+		" CREATE TABLE tenant_acme_1.items (id BIGINT PRIMARY KEY, name TEXT NOT NULL)")
+	require.NoError(t, err)
+	pool := db.NewTenantSchemaWrapper(wrapper, db.TenantSchemaConfig{
+		Tenant: func(ctx context.Context) (string, bool) {
+			tenant, ok := ctx.Value(tenantCtxKey{}).(string)
+			return tenant, ok
+		},
+		SharedSchemas: []string{"public"},
+	})
+	tenantCtx := context.WithValue(ctx, tenantCtxKey{}, "Acme-1")
+
+	_, err = pool.GetConnectionFromCtx(tenantCtx).Exec(tenantCtx, "INSERT INTO items (id, name) VALUES (1, 'tenant')")
+	require.NoError(t, err)
+	names, err := db.QueryAll[string](tenantCtx, pool, "SELECT name FROM items")
+	require.NoError(t, err)
+	require.Equal(t, []string{"tenant"}, names)
+	count, err := db.QueryOne[int64](ctx, pool, "SELECT count(*) FROM items")
+	require.NoError(t, err)
+	require.Zero(t, count, "statements without a tenant use the public table")
+
+	conns := raw.AcquireAllIdle(ctx)
+	require.NotEmpty(t, conns)
+	for _, conn := range conns {
+		var searchPath string
+		require.NoError(t, conn.QueryRow(ctx, "SHOW search_path").Scan(&searchPath))
+		require.Equal(t, `"$user", public`, searchPath)
+		conn.Release()
+	}
+}
@@ -0,0 +1,5 @@
+CREATE TABLE items
+(
+    id   BIGINT PRIMARY KEY,
+    name TEXT NOT NULL
+);
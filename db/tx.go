@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+// Propagation defines how RunInTx behaves when the context already carries a transaction.
+type Propagation int
+
+const (
+	// PropagationNested runs fn inside a savepoint of the outer transaction, or in a new transaction if there is none.
+	// A failing fn rolls back to the savepoint and leaves the outer transaction usable.
+	PropagationNested Propagation = iota
+	// PropagationRequired joins the outer transaction as is, or begins a new one if there is none.
+	PropagationRequired
+	// PropagationRequiresNew always begins an independent transaction on a separate connection.
+	PropagationRequiresNew
+	// PropagationNever runs fn without a transaction and fails if the context already carries one.
+	PropagationNever
+)
+
+var (
+	ErrTxNotAllowed       = errors.New("pgx: transaction is not allowed with PropagationNever")
+	ErrInvalidPropagation = errors.New("pgx: invalid transaction propagation")
+)
+
+type TxOption interface {
+	apply(cfg *txConfig)
+}
+
+type txConfig struct {
+	propagation      Propagation
+	retry            *RetryPolicy
+	hookErrorHandler func(ctx context.Context, err error)
+	settings         *TxSettings
+}
+
+func newTxConfig(opts []TxOption) txConfig {
+	var cfg txConfig
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	return cfg
+}
+
+func WithPropagation(propagation Propagation) TxOption {
+	return &txPropagationOption{propagation: propagation}
+}
+
+type txPropagationOption struct {
+	propagation Propagation
+}
+
+func (o *txPropagationOption) apply(cfg *txConfig) {
+	cfg.propagation = o.propagation
+}
@@ -0,0 +1,126 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/stretchr/testify/require"
+)
+
+func statementSQL(fake *dbtest.Fake) []string {
+	var sql []string
+	for _, statement := range fake.Statements() {
+		sql = append(sql, statement.SQL)
+	}
+	return sql
+}
+
+func TestRunInTx_Propagation(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name        string
+		propagation db.Propagation
+		innerErr    error
+		wantErr     error
+		statements  []string
+	}{
+		{
+			name:        "nested releases savepoint",
+			propagation: db.PropagationNested,
+			statements:  []string{"BEGIN", "SAVEPOINT sp_1", "SELECT 1", "RELEASE SAVEPOINT sp_1", "COMMIT"},
+		},
+		{
+			name:        "nested rolls back to savepoint",
+			propagation: db.PropagationNested,
+			innerErr:    boom,
+			statements:  []string{"BEGIN", "SAVEPOINT sp_1", "SELECT 1", "ROLLBACK TO SAVEPOINT sp_1", "COMMIT"},
+		},
+		{
+			name:        "required joins outer transaction",
+			propagation: db.PropagationRequired,
+			statements:  []string{"BEGIN", "SELECT 1", "COMMIT"},
+		},
+		{
+			name:        "required failure is left to the outer transaction",
+			propagation: db.PropagationRequired,
+			innerErr:    boom,
+			statements:  []string{"BEGIN", "SELECT 1", "COMMIT"},
+		},
+		{
+			name:        "requires new begins a separate transaction",
+			propagation: db.PropagationRequiresNew,
+			statements:  []string{"BEGIN", "BEGIN", "SELECT 1", "COMMIT", "COMMIT"},
+		},
+		{
+			name:        "requires new rolls back on its own",
+			propagation: db.PropagationRequiresNew,
+			innerErr:    boom,
+			statements:  []string{"BEGIN", "BEGIN", "SELECT 1", "ROLLBACK", "COMMIT"},
+		},
+		{
+			name:        "never refuses an outer transaction",
+			propagation: db.PropagationNever,
+			wantErr:     db.ErrTxNotAllowed,
+			statements:  []string{"BEGIN", "COMMIT"},
+		},
+		{
+			name:        "invalid propagation",
+			propagation: db.Propagation(42),
+			wantErr:     db.ErrInvalidPropagation,
+			statements:  []string{"BEGIN", "COMMIT"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := dbtest.NewFake(t)
+			if tt.wantErr == nil {
+				fake.Expect(dbtest.Exact("SELECT 1"))
+			}
+			wantErr := tt.wantErr
+			if wantErr == nil {
+				wantErr = tt.innerErr
+			}
+			err := fake.RunInTx(context.Background(), func(ctx context.Context) error {
+				err := fake.RunInTx(ctx, func(ctx context.Context) error {
+					if _, err := fake.GetConnectionFromCtx(ctx).Exec(ctx, "SELECT 1"); err != nil {
+						return err
+					}
+					return tt.innerErr
+				}, db.WithPropagation(tt.propagation))
+				if wantErr != nil {
+					require.ErrorIs(t, err, wantErr)
+				} else {
+					require.NoError(t, err)
+				}
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, tt.statements, statementSQL(fake))
+		})
+	}
+}
+
+func TestRunInTx_PropagationWithoutOuterTransaction(t *testing.T) {
+	tests := []struct {
+		propagation db.Propagation
+		statements  []string
+	}{
+		{propagation: db.PropagationNested, statements: []string{"BEGIN", "SELECT 1", "COMMIT"}},
+		{propagation: db.PropagationRequired, statements: []string{"BEGIN", "SELECT 1", "COMMIT"}},
+		{propagation: db.PropagationRequiresNew, statements: []string{"BEGIN", "SELECT 1", "COMMIT"}},
+		{propagation: db.PropagationNever, statements: []string{"SELECT 1"}},
+	}
+	for _, tt := range tests {
+		fake := dbtest.NewFake(t)
+		fake.Expect(dbtest.Exact("SELECT 1"))
+		err := fake.RunInTx(context.Background(), func(ctx context.Context) error {
+			_, err := fake.GetConnectionFromCtx(ctx).Exec(ctx, "SELECT 1")
+			return err
+		}, db.WithPropagation(tt.propagation))
+		require.NoError(t, err)
+		require.Equal(t, tt.statements, statementSQL(fake), "propagation %d", tt.propagation)
+	}
+}
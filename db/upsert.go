@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const DefaultUpsertChunkSize = 10000
+
+var ErrInvalidUpsertConfig = errors.New("pgx: invalid bulk upsert config")
+
+var upsertStagingSeq atomic.Uint64
+
+type UpsertConfig struct {
+	// Table is the target table, optionally schema qualified.
+	Table   string
+	Columns []string
+	// ConflictColumns must match a unique index of Table.
+	ConflictColumns []string
+	// UpdateColumns are overwritten on conflict, none means conflicting rows are left untouched.
+	UpdateColumns []string
+	// ChunkSize bounds how many rows are buffered and copied at once.
+	ChunkSize int
+}
+
+type UpsertResult struct {
+	Inserted int64
+	Updated  int64
+}
+
+// BulkUpsert copies rows into a temporary staging table and merges them into cfg.Table with
+// INSERT ... ON CONFLICT, chunk by chunk. Within the transaction carried by ctx it runs in a savepoint,
+// so a failed upsert leaves that transaction usable, otherwise it starts one.
+// Rows of the same chunk must not share conflict keys, Postgres refuses to update a row twice in one statement.
+func BulkUpsert[T any](
+	ctx context.Context, pool PgxPoolWrapper, cfg UpsertConfig, rows iter.Seq[T], values func(T) []any,
+) (UpsertResult, error) {
+	if cfg.Table == "" || len(cfg.Columns) == 0 || len(cfg.ConflictColumns) == 0 {
+		return UpsertResult{}, ErrInvalidUpsertConfig
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = DefaultUpsertChunkSize
+	}
+	staging := "bulk_upsert_" + strconv.FormatUint(upsertStagingSeq.Add(1), 10)
+	queries := newUpsertQueries(cfg, staging)
+	var result UpsertResult
+	err := pool.RunInTx(ctx, func(ctx context.Context) error {
+		conn := pool.GetConnectionFromCtx(ctx)
+		if _, err := conn.Exec(ctx, queries.create); err != nil {
+			return fmt.Errorf("create staging table: %w", err)
+		}
+		chunk := make([][]any, 0, cfg.ChunkSize)
+		flush := func() error {
+			if len(chunk) == 0 {
+				return nil
+			}
+			if _, err := conn.CopyFrom(ctx, pgx.Identifier{staging}, cfg.Columns, pgx.CopyFromRows(chunk)); err != nil {
+				return fmt.Errorf("copy rows: %w", err)
+			}
+			var inserted, updated int64
+			if err := conn.QueryRow(ctx, queries.merge).Scan(&inserted, &updated); err != nil {
+				return fmt.Errorf("merge rows: %w", err)
+			}
+			result.Inserted += inserted
+			result.Updated += updated
+			if _, err := conn.Exec(ctx, queries.truncate); err != nil {
+				return fmt.Errorf("truncate staging table: %w", err)
+			}
+			chunk = chunk[:0]
+			return nil
+		}
+		for row := range rows {
+			chunk = append(chunk, values(row))
+			if len(chunk) == cfg.ChunkSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if _, err := conn.Exec(ctx, queries.drop); err != nil {
+			return fmt.Errorf("drop staging table: %w", err)
+		}
+		return nil
+	}, WithPropagation(PropagationNested))
+	if err != nil {
+		return UpsertResult{}, ClassifyError(err)
+	}
+	return result, nil
+}
+
+type upsertQueries struct {
+	create   string
+	merge    string
+	truncate string
+	drop     string
+}
+
+func newUpsertQueries(cfg UpsertConfig, staging string) upsertQueries {
+	table := pgx.Identifier(strings.Split(cfg.Table, ".")).Sanitize()
+	stagingTable := pgx.Identifier{staging}.Sanitize()
+	columns := sanitizeColumns(cfg.Columns)
+	conflict := "DO NOTHING"
+	if len(cfg.UpdateColumns) > 0 {
+		set := make([]string, len(cfg.UpdateColumns))
+		for i, column := range cfg.UpdateColumns {
+			name := pgx.Identifier{column}.Sanitize()
+			set[i] = name + " = EXCLUDED." + name
+		}
+		conflict = "DO UPDATE SET " + strings.Join(set, ", ")
+	}
+	return upsertQueries{
+		// CREATE TABLE AS copies column types without the constraints of the target
+		create: "CREATE TEMP TABLE " + stagingTable + " ON COMMIT DROP AS SELECT " + columns +
+			" FROM " + table + " WITH NO DATA",
+		// xmax is zero only for freshly inserted row versions
+		merge: "WITH upserted AS (INSERT INTO " + table + " (" + columns + ") SELECT " + columns +
+			" FROM " + stagingTable + " ON CONFLICT (" + sanitizeColumns(cfg.ConflictColumns) + ") " + conflict +
+			" RETURNING (xmax = 0) AS inserted)" +
+			" SELECT count(*) FILTER (WHERE inserted), count(*) FILTER (WHERE NOT inserted) FROM upserted",
+		truncate: "TRUNCATE " + stagingTable,
+		drop:     "DROP TABLE " + stagingTable,
+	}
+}
+
+func sanitizeColumns(columns []string) string {
+	sanitized := make([]string, len(columns))
+	for i, column := range columns {
+		sanitized[i] = pgx.Identifier{column}.Sanitize()
+	}
+	return strings.Join(sanitized, ", ")
+}
@@ -0,0 +1,63 @@
+package db_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+var itemsUpsert = db.UpsertConfig{
+	Table:           "items",
+	Columns:         []string{"id", "name"},
+	ConflictColumns: []string{"id"},
+	UpdateColumns:   []string{"name"},
+	ChunkSize:       2,
+}
+
+func itemValues(i item) []any {
+	return []any{i.ID, i.Name}
+}
+
+func TestBulkUpsert_CountsInsertedAndUpdated(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	ctx := context.Background()
+	_, err := wrapper.GetConnectionFromCtx(ctx).Exec(ctx, "INSERT INTO items (id, name) VALUES (1, 'a'), (2, 'b')")
+	require.NoError(t, err)
+
+	rows := []item{{ID: 1, Name: "a2"}, {ID: 3, Name: "c"}, {ID: 2, Name: "b2"}, {ID: 4, Name: "d"}, {ID: 5, Name: "e"}}
+	result, err := db.BulkUpsert(ctx, wrapper, itemsUpsert, slices.Values(rows), itemValues)
+	require.NoError(t, err)
+	require.Equal(t, db.UpsertResult{Inserted: 3, Updated: 2}, result)
+
+	stored, err := db.QueryAll[item](ctx, wrapper, "SELECT id, name FROM items ORDER BY id")
+	require.NoError(t, err)
+	require.Equal(t, []item{{ID: 1, Name: "a2"}, {ID: 2, Name: "b2"}, {ID: 3, Name: "c"}, {ID: 4, Name: "d"}, {ID: 5, Name: "e"}}, stored)
+}
+
+func TestBulkUpsert_FailureKeepsOuterTransactionUsable(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	ctx := context.Background()
+
+	err := wrapper.RunInTx(ctx, func(ctx context.Context) error {
+		// The same conflict key twice in one chunk cannot be merged
+		rows := []item{{ID: 1, Name: "a"}, {ID: 1, Name: "b"}}
+		_, err := db.BulkUpsert(ctx, wrapper, itemsUpsert, slices.Values(rows), itemValues)
+		require.Error(t, err)
+		_, err = wrapper.GetConnectionFromCtx(ctx).Exec(ctx, "INSERT INTO items (id, name) VALUES (2, 'b')")
+		return err
+	})
+	require.NoError(t, err)
+
+	count, err := db.QueryOne[int](ctx, wrapper, "SELECT count(*) FROM items")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
@@ -0,0 +1,15 @@
+package flags
+
+import "context"
+
+type userIDCtxKey struct{}
+
+// ContextWithUserID attaches the user that percentage and allowlist flags are evaluated for.
+func ContextWithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDCtxKey{}, userID)
+}
+
+func UserIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDCtxKey{}).(string)
+	return userID
+}
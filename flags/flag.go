@@ -0,0 +1,75 @@
+package flags
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+)
+
+type Type string
+
+const (
+	TypeBool       Type = "bool"
+	TypePercentage Type = "percentage"
+	TypeAllowlist  Type = "allowlist"
+)
+
+func (t *Type) UnmarshalText(text []byte) error {
+	flagType := Type(strings.ToLower(strings.TrimSpace(string(text))))
+	switch flagType {
+	case TypeBool, TypePercentage, TypeAllowlist:
+		*t = flagType
+		return nil
+	default:
+		return fmt.Errorf("invalid flag type: %q", string(text))
+	}
+}
+
+// Flag is a single feature toggle. Enabled acts as a kill switch for every type:
+// percentage and allowlist flags are never on while it is false.
+type Flag struct {
+	Name       string   `json:"name"`
+	Type       Type     `json:"type"`
+	Enabled    bool     `json:"enabled"`
+	Percentage float64  `json:"percentage,omitempty"`
+	Users      []string `json:"users,omitempty"`
+}
+
+// Evaluate reports whether the flag is on for the given user.
+// Percentage rollouts are sticky: the same user always lands in the same bucket of a flag.
+func (f Flag) Evaluate(userID string) bool {
+	if !f.Enabled {
+		return false
+	}
+	switch f.Type {
+	case TypeBool, "":
+		return true
+	case TypePercentage:
+		if f.Percentage >= 100 {
+			return true
+		}
+		if userID == "" || f.Percentage <= 0 {
+			return false
+		}
+		return float64(bucket(f.Name, userID)) < f.Percentage*100
+	case TypeAllowlist:
+		return userID != "" && slices.Contains(f.Users, userID)
+	default:
+		return false
+	}
+}
+
+// bucket maps the user onto one of 10000 buckets, seeded by the flag name so rollouts of different flags are independent.
+func bucket(name string, userID string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(userID))
+	return h.Sum32() % 10000
+}
+
+// NormalizeName makes flag names comparable across sources: "NEW_CHECKOUT" and "new-checkout" are the same flag.
+func NormalizeName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "_", "-")
+}
@@ -0,0 +1,63 @@
+package flags_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/IndexStorm/common-go/config"
+	"github.com/IndexStorm/common-go/flags"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlag_EvaluatePercentageIsSticky(t *testing.T) {
+	flag := flags.Flag{Name: "new-checkout", Type: flags.TypePercentage, Enabled: true, Percentage: 30}
+
+	enabled := 0
+	for i := range 10000 {
+		userID := "user-" + strconv.Itoa(i)
+		result := flag.Evaluate(userID)
+		require.Equal(t, result, flag.Evaluate(userID))
+		if result {
+			enabled++
+		}
+	}
+	require.InDelta(t, 3000, enabled, 300)
+	require.False(t, flag.Evaluate(""))
+}
+
+func TestFlag_EvaluateAllowlist(t *testing.T) {
+	flag := flags.Flag{Name: "beta", Type: flags.TypeAllowlist, Enabled: true, Users: []string{"u1"}}
+	require.True(t, flag.Evaluate("u1"))
+	require.False(t, flag.Evaluate("u2"))
+
+	flag.Enabled = false
+	require.False(t, flag.Evaluate("u1"))
+}
+
+func TestService_SourcesOverrideDefaults(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	t.Cleanup(cancel)
+	t.Setenv("FEATURE_FLAG_NEW_CHECKOUT", "false")
+	t.Setenv("FEATURE_FLAG_BETA", "users:u1, u2")
+
+	service, err := flags.NewService(ctx, flags.ServiceConfig{
+		Environment: config.EnvironmentStage,
+		Defaults: map[config.Environment][]flags.Flag{
+			config.EnvironmentStage: {
+				{Name: "new-checkout", Type: flags.TypeBool, Enabled: true},
+				{Name: "search-v2", Type: flags.TypeBool, Enabled: true},
+			},
+		},
+		Sources: []flags.Source{flags.NewEnvSource("")},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = service.Shutdown(ctx) })
+
+	require.False(t, service.Enabled(ctx, "new-checkout"))
+	require.True(t, service.Enabled(ctx, "search-v2"))
+	require.False(t, service.Enabled(ctx, "beta"))
+	require.True(t, service.Enabled(flags.ContextWithUserID(ctx, "u2"), "beta"))
+	require.False(t, service.Enabled(ctx, "unknown"))
+}
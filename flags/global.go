@@ -0,0 +1,22 @@
+package flags
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+var defaultService atomic.Pointer[Service]
+
+// SetDefault installs the service used by the package-level Enabled.
+func SetDefault(s Service) {
+	defaultService.Store(&s)
+}
+
+// Enabled evaluates the flag with the default service. Every flag is off until SetDefault is called.
+func Enabled(ctx context.Context, name string) bool {
+	s := defaultService.Load()
+	if s == nil {
+		return false
+	}
+	return (*s).Enabled(ctx, name)
+}
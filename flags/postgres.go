@@ -0,0 +1,59 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/jackc/pgx/v5"
+)
+
+const DefaultPostgresTable = "feature_flags"
+
+type postgresSource struct {
+	pool  db.PgxPoolWrapper
+	query string
+}
+
+// NewPostgresSource reads live flag values from a table with the following layout:
+//
+//	CREATE TABLE feature_flags (
+//	    name       TEXT PRIMARY KEY,
+//	    type       TEXT NOT NULL DEFAULT 'bool',
+//	    enabled    BOOLEAN NOT NULL DEFAULT FALSE,
+//	    percentage DOUBLE PRECISION NOT NULL DEFAULT 0,
+//	    users      TEXT[] NOT NULL DEFAULT '{}'
+//	);
+func NewPostgresSource(pool db.PgxPoolWrapper, table string) Source {
+	if table == "" {
+		table = DefaultPostgresTable
+	}
+	return &postgresSource{
+		pool: pool,
+		query: "SELECT name, type, enabled, percentage, users FROM " +
+			pgx.Identifier(strings.Split(table, ".")).Sanitize(),
+	}
+}
+
+func (s *postgresSource) Load(ctx context.Context) ([]Flag, error) {
+	rows, err := s.pool.GetConnectionFromCtx(ctx).Query(ctx, s.query)
+	if err != nil {
+		return nil, fmt.Errorf("query flags: %w", err)
+	}
+	flags, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Flag, error) {
+		var flag Flag
+		var flagType string
+		if err := row.Scan(&flag.Name, &flagType, &flag.Enabled, &flag.Percentage, &flag.Users); err != nil {
+			return Flag{}, err
+		}
+		if err := flag.Type.UnmarshalText([]byte(flagType)); err != nil {
+			return Flag{}, fmt.Errorf("flag %s: %w", flag.Name, err)
+		}
+		return flag, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("collect flags: %w", err)
+	}
+	return flags, nil
+}
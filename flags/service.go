@@ -0,0 +1,138 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IndexStorm/common-go/config"
+	"github.com/IndexStorm/common-go/termination"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const DefaultRefreshInterval = time.Minute
+
+type Service interface {
+	termination.Shutdowner
+	Enabled(ctx context.Context, name string) bool
+	Lookup(name string) (Flag, bool)
+	Refresh(ctx context.Context) error
+}
+
+// ServiceConfig describes where flag values come from. Defaults for the current Environment
+// are applied first, then every source in order, so later sources override earlier ones.
+// A typical order is the Postgres source for live values followed by env/file overrides.
+type ServiceConfig struct {
+	Environment     config.Environment
+	Defaults        map[config.Environment][]Flag
+	Sources         []Source
+	RefreshInterval time.Duration
+	Logger          zerolog.Logger
+}
+
+type service struct {
+	config    ServiceConfig
+	flags     atomic.Pointer[map[string]Flag]
+	mu        sync.Mutex
+	snapshots [][]Flag
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewService loads all sources once and then keeps refreshing them in the background.
+// A source failing on a later refresh keeps serving its last successful snapshot.
+func NewService(ctx context.Context, cfg ServiceConfig) (Service, error) {
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = DefaultRefreshInterval
+	}
+	s := &service{
+		config:    cfg,
+		snapshots: make([][]Flag, len(cfg.Sources)),
+		done:      make(chan struct{}),
+	}
+	if err := s.Refresh(ctx); err != nil {
+		return nil, fmt.Errorf("initial refresh: %w", err)
+	}
+	go s.refreshLoop()
+	return s, nil
+}
+
+func (s *service) Enabled(ctx context.Context, name string) bool {
+	name = NormalizeName(name)
+	flag, _ := s.Lookup(name)
+	enabled := flag.Evaluate(UserIDFromContext(ctx))
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		span.SetAttributes(attribute.Bool("feature_flag."+name, enabled))
+	}
+	return enabled
+}
+
+func (s *service) Lookup(name string) (Flag, bool) {
+	flags := s.flags.Load()
+	if flags == nil {
+		return Flag{}, false
+	}
+	flag, ok := (*flags)[NormalizeName(name)]
+	return flag, ok
+}
+
+func (s *service) Refresh(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for i, source := range s.config.Sources {
+		flags, err := source.Load(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("load source %d: %w", i, err))
+			continue
+		}
+		s.snapshots[i] = flags
+	}
+	merged := make(map[string]Flag)
+	for _, flag := range s.config.Defaults[s.config.Environment] {
+		merged[NormalizeName(flag.Name)] = flag
+	}
+	for _, snapshot := range s.snapshots {
+		for _, flag := range snapshot {
+			merged[NormalizeName(flag.Name)] = flag
+		}
+	}
+	for name, flag := range merged {
+		flag.Name = name
+		merged[name] = flag
+	}
+	s.flags.Store(&merged)
+	if len(errs) > 0 {
+		return fmt.Errorf("refresh flags: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (s *service) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	return nil
+}
+
+func (s *service) refreshLoop() {
+	ticker := time.NewTicker(s.config.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), s.config.RefreshInterval)
+			if err := s.Refresh(ctx); err != nil {
+				s.config.Logger.Warn().Err(err).Msg("failed to refresh feature flags")
+			}
+			cancel()
+		}
+	}
+}
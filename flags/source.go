@@ -0,0 +1,99 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+const DefaultEnvPrefix = "FEATURE_FLAG_"
+
+const envAllowlistPrefix = "users:"
+
+type Source interface {
+	Load(ctx context.Context) ([]Flag, error)
+}
+
+type envSource struct {
+	prefix string
+}
+
+// NewEnvSource reads flags from variables like FEATURE_FLAG_NEW_CHECKOUT. Accepted values are
+// a boolean ("true"), a rollout percentage ("25%") or an allowlist ("users:u1,u2").
+func NewEnvSource(prefix string) Source {
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+	return &envSource{prefix: prefix}
+}
+
+func (s *envSource) Load(ctx context.Context) ([]Flag, error) {
+	var flags []Flag
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, s.prefix) || len(key) == len(s.prefix) {
+			continue
+		}
+		flag, err := parseEnvFlag(key[len(s.prefix):], value)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", key, err)
+		}
+		flags = append(flags, flag)
+	}
+	return flags, nil
+}
+
+func parseEnvFlag(name string, value string) (Flag, error) {
+	value = strings.TrimSpace(value)
+	flag := Flag{Name: name, Enabled: true}
+	if users, ok := strings.CutPrefix(value, envAllowlistPrefix); ok {
+		flag.Type = TypeAllowlist
+		for _, user := range strings.Split(users, ",") {
+			if user = strings.TrimSpace(user); user != "" {
+				flag.Users = append(flag.Users, user)
+			}
+		}
+		return flag, nil
+	}
+	if percentage, ok := strings.CutSuffix(value, "%"); ok {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(percentage), 64)
+		if err != nil || parsed < 0 || parsed > 100 {
+			return Flag{}, fmt.Errorf("invalid percentage: %q", value)
+		}
+		flag.Type = TypePercentage
+		flag.Percentage = parsed
+		return flag, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return Flag{}, fmt.Errorf("invalid value: %q", value)
+	}
+	flag.Type = TypeBool
+	flag.Enabled = enabled
+	return flag, nil
+}
+
+type fileSource struct {
+	path string
+}
+
+// NewFileSource reads a JSON array of flags from path on every refresh.
+func NewFileSource(path string) Source {
+	return &fileSource{path: path}
+}
+
+func (s *fileSource) Load(ctx context.Context) ([]Flag, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+	var flags []Flag
+	if err = json.Unmarshal(data, &flags); err != nil {
+		return nil, fmt.Errorf("unmarshal flags: %w", err)
+	}
+	return flags, nil
+}
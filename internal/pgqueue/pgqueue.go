@@ -0,0 +1,56 @@
+// Package pgqueue holds the plumbing shared by the table backed queues, the outbox relay and the job worker.
+package pgqueue
+
+import (
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// TracePropagator carries the trace context of the producer in the headers column.
+var TracePropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// SanitizeTable quotes an optionally schema qualified table name, defaultTable when it is empty.
+func SanitizeTable(table string, defaultTable string) string {
+	if table == "" {
+		table = defaultTable
+	}
+	return pgx.Identifier(strings.Split(table, ".")).Sanitize()
+}
+
+// Backoff doubles initial per attempt up to max, with jitter in its upper half so that
+// entries failing together are not retried together.
+func Backoff(initial time.Duration, max time.Duration, attempt int) time.Duration {
+	backoff := initial
+	for range attempt - 1 {
+		backoff *= 2
+		if backoff >= max {
+			backoff = max
+			break
+		}
+	}
+	return backoff/2 + time.Duration(rand.Int64N(int64(backoff/2)+1))
+}
+
+// Poll calls poll until done is closed, immediately again while it reports more work
+// and after interval otherwise.
+func Poll(done <-chan struct{}, interval time.Duration, poll func() (more bool)) {
+	for {
+		if poll() {
+			select {
+			case <-done:
+				return
+			default:
+				continue
+			}
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package pgqueue_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IndexStorm/common-go/internal/pgqueue"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeTable(t *testing.T) {
+	require.Equal(t, `"jobs"`, pgqueue.SanitizeTable("", "jobs"))
+	require.Equal(t, `"queue"."jobs"`, pgqueue.SanitizeTable("queue.jobs", "jobs"))
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{attempt: 1, min: time.Second / 2, max: time.Second},
+		{attempt: 3, min: time.Second * 2, max: time.Second * 4},
+		{attempt: 20, min: time.Second * 5, max: time.Second * 10},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			backoff := pgqueue.Backoff(time.Second, time.Second*10, tt.attempt)
+			require.GreaterOrEqual(t, backoff, tt.min, "attempt %d", tt.attempt)
+			require.LessOrEqual(t, backoff, tt.max, "attempt %d", tt.attempt)
+		}
+	}
+}
+
+func TestPoll(t *testing.T) {
+	done := make(chan struct{})
+	polls := 0
+	pgqueue.Poll(done, time.Hour, func() bool {
+		polls++
+		// Reporting more work skips the hour long interval
+		if polls == 3 {
+			close(done)
+		}
+		return true
+	})
+	require.Equal(t, 3, polls)
+}
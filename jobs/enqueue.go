@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/internal/pgqueue"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+type Enqueuer interface {
+	// Enqueue inserts job through the connection carried by ctx, so a job enqueued within a transaction
+	// is picked up only after the commit. It returns ErrDuplicateJob when the unique key is taken.
+	Enqueue(ctx context.Context, job Job) (int64, error)
+}
+
+type enqueuer struct {
+	pool  db.PgxPoolWrapper
+	query string
+}
+
+func NewEnqueuer(pool db.PgxPoolWrapper, table string) Enqueuer {
+	return &enqueuer{
+		pool: pool,
+		query: "INSERT INTO " + pgqueue.SanitizeTable(table, DefaultTable) +
+			" (kind, queue, payload, headers, priority, max_attempts, timeout_ms, unique_key, run_at)" +
+			" VALUES ($1, $2, $3, $4, $5, $6, $7, $8, COALESCE($9, now()))" +
+			" ON CONFLICT (kind, unique_key)" +
+			" WHERE unique_key IS NOT NULL AND status IN ('" + statusPending + "', '" + statusRunning + "')" +
+			" DO NOTHING RETURNING id",
+	}
+}
+
+func (e *enqueuer) Enqueue(ctx context.Context, job Job) (int64, error) {
+	if job.Queue == "" {
+		job.Queue = DefaultQueue
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = DefaultMaxAttempts
+	}
+	payload := job.Payload
+	if payload == nil {
+		payload = []byte("null")
+	}
+	headers := make(map[string]string, 2)
+	pgqueue.TracePropagator.Inject(ctx, propagation.MapCarrier(headers))
+	var uniqueKey, runAt any
+	if job.UniqueKey != "" {
+		uniqueKey = job.UniqueKey
+	}
+	if !job.RunAt.IsZero() {
+		runAt = job.RunAt
+	}
+	var id int64
+	err := e.pool.GetConnectionFromCtx(ctx).QueryRow(ctx, e.query,
+		job.Kind, job.Queue, payload, headers, job.Priority, job.MaxAttempts,
+		job.Timeout.Milliseconds(), uniqueKey, runAt,
+	).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrDuplicateJob
+	}
+	if err != nil {
+		return 0, fmt.Errorf("insert job %s: %w", job.Kind, err)
+	}
+	return id, nil
+}
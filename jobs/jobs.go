@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"time"
+)
+
+// Migrations creates the DefaultTable jobs table with the indexes used for fetching, rescuing
+// stale jobs and enforcing unique keys. They are golang-migrate files, like the service migrations.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+const (
+	DefaultTable       = "jobs"
+	DefaultQueue       = "default"
+	DefaultMaxAttempts = 25
+)
+
+const (
+	statusPending = "pending"
+	statusRunning = "running"
+	statusDone    = "done"
+	statusDead    = "dead"
+)
+
+var ErrDuplicateJob = errors.New("jobs: a job with the same unique key is already pending or running")
+
+// Job describes work to be enqueued. Payload must be valid JSON, nil is stored as null.
+type Job struct {
+	Kind     string
+	Queue    string
+	Payload  []byte
+	Priority int
+	// RunAt schedules the job, zero means as soon as possible.
+	RunAt       time.Time
+	MaxAttempts int
+	// Timeout bounds a single attempt, zero falls back to the worker default.
+	Timeout time.Duration
+	// UniqueKey prevents enqueueing another job of the same kind while one with the key is pending or running.
+	UniqueKey string
+}
+
+// Record is a job fetched by a worker. Headers include the trace context of the enqueuer.
+type Record struct {
+	ID          int64
+	Kind        string
+	Queue       string
+	Payload     []byte
+	Headers     map[string]string
+	Priority    int
+	Attempt     int
+	MaxAttempts int
+	Timeout     time.Duration
+	CreatedAt   time.Time
+}
+
+type Handler func(ctx context.Context, job Record) error
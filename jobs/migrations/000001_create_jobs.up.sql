@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS jobs
+(
+    id           BIGSERIAL PRIMARY KEY,
+    kind         TEXT        NOT NULL,
+    queue        TEXT        NOT NULL DEFAULT 'default',
+    payload      JSONB       NOT NULL DEFAULT 'null',
+    headers      JSONB       NOT NULL DEFAULT '{}',
+    priority     INTEGER     NOT NULL DEFAULT 0,
+    status       TEXT        NOT NULL DEFAULT 'pending',
+    attempts     INTEGER     NOT NULL DEFAULT 0,
+    max_attempts INTEGER     NOT NULL,
+    timeout_ms   BIGINT      NOT NULL DEFAULT 0,
+    unique_key   TEXT,
+    last_error   TEXT,
+    run_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    heartbeat_at TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS jobs_pending_idx
+    ON jobs (queue, priority DESC, run_at, id)
+    WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS jobs_running_idx
+    ON jobs (heartbeat_at)
+    WHERE status = 'running';
+
+CREATE UNIQUE INDEX IF NOT EXISTS jobs_unique_idx
+    ON jobs (kind, unique_key)
+    WHERE unique_key IS NOT NULL AND status IN ('pending', 'running');
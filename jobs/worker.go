@@ -0,0 +1,342 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/internal/pgqueue"
+	"github.com/IndexStorm/common-go/termination"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// releaseGracePeriod bounds how long Shutdown waits for canceled jobs to be put back to the queue.
+const releaseGracePeriod = time.Second * 5
+
+var errHeartbeatLost = errors.New("heartbeat lost")
+
+type WorkerConfig struct {
+	Table string
+	// Queues to fetch jobs from, DefaultQueue when empty.
+	Queues       []string
+	Concurrency  int
+	PollInterval time.Duration
+	// HeartbeatInterval is how often running jobs are marked alive.
+	// Jobs without a heartbeat for StaleAfter are taken back from crashed workers and retried.
+	HeartbeatInterval time.Duration
+	StaleAfter        time.Duration
+	// DefaultTimeout bounds attempts of jobs enqueued without a timeout.
+	DefaultTimeout time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Logger         zerolog.Logger
+}
+
+type Worker interface {
+	termination.Shutdowner
+	// Handle registers handler for kind. Only kinds with a handler are fetched, so workers of
+	// different services can share the table. Handlers may be added after the worker started.
+	Handle(kind string, handler Handler)
+}
+
+type worker struct {
+	pool      db.PgxPoolWrapper
+	config    WorkerConfig
+	tracer    trace.Tracer
+	queries   workerQueries
+	mu        sync.Mutex
+	handlers  map[string]Handler
+	running   map[int64]struct{}
+	slots     chan struct{}
+	jobs      sync.WaitGroup
+	jobsCtx   context.Context
+	stopJobs  context.CancelFunc
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+type workerQueries struct {
+	fetch     string
+	done      string
+	retry     string
+	dead      string
+	release   string
+	heartbeat string
+	rescue    string
+}
+
+// NewWorker starts fetching jobs with FOR UPDATE SKIP LOCKED, highest priority first.
+// A fetched job is marked running in its own statement, so no transaction stays open while it executes.
+// Delivery is at-least-once: handlers must tolerate a job running again after a crash.
+func NewWorker(pool db.PgxPoolWrapper, cfg WorkerConfig) Worker {
+	if len(cfg.Queues) == 0 {
+		cfg.Queues = []string{DefaultQueue}
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 10
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = time.Second * 10
+	}
+	if cfg.StaleAfter <= cfg.HeartbeatInterval {
+		cfg.StaleAfter = cfg.HeartbeatInterval * 6
+	}
+	if cfg.DefaultTimeout <= 0 {
+		cfg.DefaultTimeout = time.Minute * 30
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Hour
+	}
+	table := pgqueue.SanitizeTable(cfg.Table, DefaultTable)
+	// Outcomes are stored only while the job is still running the attempt of this worker,
+	// a job rescued and fetched again by another worker belongs to it
+	owned := " WHERE id = $1 AND status = '" + statusRunning + "' AND attempts = $2"
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	w := &worker{
+		pool:   pool,
+		config: cfg,
+		tracer: otel.Tracer("github.com/IndexStorm/common-go/jobs"),
+		queries: workerQueries{
+			fetch: "UPDATE " + table + " SET status = '" + statusRunning + "', attempts = attempts + 1," +
+				" heartbeat_at = now() WHERE id IN (SELECT id FROM " + table +
+				" WHERE status = '" + statusPending + "' AND run_at <= now() AND queue = ANY($1) AND kind = ANY($2)" +
+				" ORDER BY priority DESC, run_at, id LIMIT $3 FOR UPDATE SKIP LOCKED)" +
+				" RETURNING id, kind, queue, payload, headers, priority, attempts, max_attempts, timeout_ms, created_at",
+			done: "UPDATE " + table + " SET status = '" + statusDone + "', heartbeat_at = NULL," +
+				" last_error = NULL, finished_at = now()" + owned,
+			retry: "UPDATE " + table + " SET status = '" + statusPending + "', heartbeat_at = NULL," +
+				" last_error = $3, run_at = now() + $4::interval" + owned,
+			dead: "UPDATE " + table + " SET status = '" + statusDead + "', heartbeat_at = NULL," +
+				" last_error = $3, finished_at = now()" + owned,
+			// An attempt interrupted by shutdown does not count
+			release: "UPDATE " + table + " SET status = '" + statusPending + "', heartbeat_at = NULL," +
+				" attempts = attempts - 1, run_at = now()" + owned,
+			heartbeat: "UPDATE " + table + " SET heartbeat_at = now()" +
+				" WHERE id = ANY($1) AND status = '" + statusRunning + "'",
+			rescue: "UPDATE " + table + " SET heartbeat_at = NULL, last_error = '" + errHeartbeatLost.Error() + "'," +
+				" status = CASE WHEN attempts >= max_attempts THEN '" + statusDead + "' ELSE '" + statusPending + "' END," +
+				" finished_at = CASE WHEN attempts >= max_attempts THEN now() END, run_at = now()" +
+				" WHERE status = '" + statusRunning + "' AND heartbeat_at < now() - $1::interval",
+		},
+		handlers: make(map[string]Handler),
+		running:  make(map[int64]struct{}),
+		slots:    make(chan struct{}, cfg.Concurrency),
+		jobsCtx:  jobsCtx,
+		stopJobs: stopJobs,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go w.loop()
+	go w.heartbeatLoop()
+	return w
+}
+
+func (w *worker) Handle(kind string, handler Handler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[kind] = handler
+}
+
+// Shutdown stops fetching and waits for running jobs to drain. When ctx expires first,
+// running jobs are canceled and put back to the queue without consuming an attempt.
+// Handlers ignoring the cancellation are left behind after a short grace period,
+// their jobs are rescued once their heartbeat is stale.
+func (w *worker) Shutdown(ctx context.Context) error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+	select {
+	case <-w.stopped:
+		return nil
+	case <-ctx.Done():
+		w.stopJobs()
+		select {
+		case <-w.stopped:
+		case <-time.After(releaseGracePeriod):
+		}
+		return ctx.Err()
+	}
+}
+
+func (w *worker) loop() {
+	defer close(w.stopped)
+	defer w.jobs.Wait()
+	ctx := context.Background()
+	pgqueue.Poll(w.done, w.config.PollInterval, func() bool {
+		free := cap(w.slots) - len(w.slots)
+		if free == 0 {
+			// Wait for a running job to finish
+			select {
+			case <-w.done:
+				return false
+			case w.slots <- struct{}{}:
+				<-w.slots
+				return true
+			}
+		}
+		fetched, err := w.fetch(ctx, free)
+		if err != nil {
+			w.config.Logger.Error().Err(err).Msg("failed to fetch jobs")
+		}
+		for _, job := range fetched {
+			w.start(job)
+		}
+		// Every slot was filled, fetch again as soon as one frees up
+		return err == nil && len(fetched) == free
+	})
+}
+
+func (w *worker) fetch(ctx context.Context, limit int) ([]Record, error) {
+	w.mu.Lock()
+	kinds := slices.Collect(maps.Keys(w.handlers))
+	w.mu.Unlock()
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+	rows, err := w.pool.GetConnectionFromCtx(ctx).Query(ctx, w.queries.fetch, w.config.Queues, kinds, limit)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jobs: %w", err)
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Record, error) {
+		var job Record
+		var timeoutMs int64
+		err := row.Scan(&job.ID, &job.Kind, &job.Queue, &job.Payload, &job.Headers, &job.Priority,
+			&job.Attempt, &job.MaxAttempts, &timeoutMs, &job.CreatedAt)
+		job.Timeout = time.Duration(timeoutMs) * time.Millisecond
+		return job, err
+	})
+}
+
+func (w *worker) start(job Record) {
+	w.slots <- struct{}{}
+	w.mu.Lock()
+	w.running[job.ID] = struct{}{}
+	handler := w.handlers[job.Kind]
+	w.mu.Unlock()
+	w.jobs.Add(1)
+	go func() {
+		defer w.jobs.Done()
+		defer func() {
+			w.mu.Lock()
+			delete(w.running, job.ID)
+			w.mu.Unlock()
+			<-w.slots
+		}()
+		w.execute(job, handler)
+	}()
+}
+
+func (w *worker) execute(job Record, handler Handler) {
+	timeout := job.Timeout
+	if timeout <= 0 {
+		timeout = w.config.DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(w.jobsCtx, timeout)
+	defer cancel()
+	err := w.run(ctx, job, handler)
+	// Outcomes are stored even when the job context is gone
+	storeCtx := context.Background()
+	conn := w.pool.GetConnectionFromCtx(storeCtx)
+	var tag pgconn.CommandTag
+	switch {
+	case err == nil:
+		tag, err = conn.Exec(storeCtx, w.queries.done, job.ID, job.Attempt)
+	case w.jobsCtx.Err() != nil:
+		tag, err = conn.Exec(storeCtx, w.queries.release, job.ID, job.Attempt)
+	case job.Attempt >= job.MaxAttempts:
+		w.config.Logger.Error().Err(err).Int64("job_id", job.ID).Str("kind", job.Kind).Msg("job moved to dead state")
+		tag, err = conn.Exec(storeCtx, w.queries.dead, job.ID, job.Attempt, err.Error())
+	default:
+		backoff := pgqueue.Backoff(w.config.InitialBackoff, w.config.MaxBackoff, job.Attempt)
+		tag, err = conn.Exec(storeCtx, w.queries.retry, job.ID, job.Attempt, err.Error(), backoff)
+	}
+	if err != nil {
+		w.config.Logger.Error().Err(err).Int64("job_id", job.ID).Msg("failed to store job result")
+	} else if tag.RowsAffected() == 0 {
+		w.config.Logger.Warn().Int64("job_id", job.ID).Int("attempt", job.Attempt).
+			Msg("job was rescued from this worker before its result was stored, the result is dropped")
+	}
+}
+
+// run executes handler in a span linked to the trace of the enqueuer.
+func (w *worker) run(ctx context.Context, job Record, handler Handler) (err error) {
+	enqueuer := trace.SpanContextFromContext(pgqueue.TracePropagator.Extract(ctx, propagation.MapCarrier(job.Headers)))
+	ctx, span := w.tracer.Start(ctx, "process "+job.Kind,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(trace.Link{SpanContext: enqueuer}),
+		trace.WithAttributes(
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(job.Queue),
+			attribute.String("job.kind", job.Kind),
+			attribute.Int64("job.id", job.ID),
+			attribute.Int("job.attempt", job.Attempt),
+		),
+	)
+	defer span.End()
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("handler panic: %v", rec)
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}()
+	if handler == nil {
+		return fmt.Errorf("no handler for kind %s", job.Kind)
+	}
+	return handler(ctx, job)
+}
+
+// heartbeatLoop marks running jobs alive and rescues the ones abandoned by crashed workers.
+func (w *worker) heartbeatLoop() {
+	ticker := time.NewTicker(w.config.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopped:
+			return
+		case <-w.jobsCtx.Done():
+			// Canceled jobs stop heartbeating, so the ones whose handler ignores the cancellation get rescued
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), w.config.HeartbeatInterval)
+		conn := w.pool.GetConnectionFromCtx(ctx)
+		w.mu.Lock()
+		ids := slices.Collect(maps.Keys(w.running))
+		w.mu.Unlock()
+		if len(ids) > 0 {
+			if _, err := conn.Exec(ctx, w.queries.heartbeat, ids); err != nil {
+				w.config.Logger.Warn().Err(err).Msg("failed to heartbeat jobs")
+			}
+		}
+		tag, err := conn.Exec(ctx, w.queries.rescue, w.config.StaleAfter)
+		if err != nil {
+			w.config.Logger.Warn().Err(err).Msg("failed to rescue stale jobs")
+		} else if tag.RowsAffected() > 0 {
+			w.config.Logger.Warn().Int64("count", tag.RowsAffected()).Msg("rescued jobs of crashed workers")
+		}
+		cancel()
+	}
+}
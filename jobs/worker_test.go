@@ -0,0 +1,215 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/IndexStorm/common-go/jobs"
+	"github.com/stretchr/testify/require"
+)
+
+const schemaDir = "migrations"
+
+type jobState struct {
+	Status    string
+	Attempts  int
+	LastError *string
+}
+
+func newWorker(t *testing.T, wrapper db.PgxPoolWrapper, cfg jobs.WorkerConfig) jobs.Worker {
+	cfg.PollInterval = time.Millisecond * 20
+	worker := jobs.NewWorker(wrapper, cfg)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		_ = worker.Shutdown(ctx)
+	})
+	return worker
+}
+
+func enqueue(t *testing.T, wrapper db.PgxPoolWrapper, job jobs.Job) int64 {
+	id, err := jobs.NewEnqueuer(wrapper, "").Enqueue(context.Background(), job)
+	require.NoError(t, err)
+	return id
+}
+
+func queryState(t *testing.T, wrapper db.PgxPoolWrapper, id int64) jobState {
+	state, err := db.QueryOne[jobState](context.Background(), wrapper,
+		"SELECT status, attempts, last_error FROM jobs WHERE id = $1", id)
+	require.NoError(t, err)
+	return state
+}
+
+func waitStatus(t *testing.T, wrapper db.PgxPoolWrapper, id int64, status string) jobState {
+	var state jobState
+	require.Eventually(t, func() bool {
+		state = queryState(t, wrapper, id)
+		return state.Status == status
+	}, time.Second*5, time.Millisecond*20)
+	return state
+}
+
+func exec(t *testing.T, wrapper db.PgxPoolWrapper, sql string, args ...any) {
+	_, err := wrapper.GetConnectionFromCtx(context.Background()).Exec(context.Background(), sql, args...)
+	require.NoError(t, err)
+}
+
+// recorder is a handler remembering the jobs it ran.
+type recorder struct {
+	mu   sync.Mutex
+	runs []jobs.Record
+	err  func(job jobs.Record) error
+}
+
+func (r *recorder) Handle(_ context.Context, job jobs.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, job)
+	if r.err != nil {
+		return r.err(job)
+	}
+	return nil
+}
+
+func (r *recorder) Runs() []jobs.Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]jobs.Record(nil), r.runs...)
+}
+
+func TestEnqueue_DuplicateUniqueKey(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	job := jobs.Job{Kind: "email", UniqueKey: "user-1"}
+	first := enqueue(t, wrapper, job)
+
+	_, err := jobs.NewEnqueuer(wrapper, "").Enqueue(context.Background(), job)
+	require.ErrorIs(t, err, jobs.ErrDuplicateJob)
+	// The key only applies to the same kind
+	enqueue(t, wrapper, jobs.Job{Kind: "sms", UniqueKey: "user-1"})
+
+	// The key is free again once the job is finished
+	exec(t, wrapper, "UPDATE jobs SET status = 'done' WHERE id = $1", first)
+	require.NotEqual(t, first, enqueue(t, wrapper, job))
+}
+
+func TestWorker_RunsByPriorityThenRunAt(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	now := time.Now()
+	late := enqueue(t, wrapper, jobs.Job{Kind: "report", RunAt: now.Add(-time.Minute)})
+	urgent := enqueue(t, wrapper, jobs.Job{Kind: "report", Priority: 5})
+	early := enqueue(t, wrapper, jobs.Job{Kind: "report", RunAt: now.Add(-time.Minute * 2)})
+	scheduled := enqueue(t, wrapper, jobs.Job{Kind: "report", RunAt: now.Add(time.Hour)})
+	handler := &recorder{}
+	worker := newWorker(t, wrapper, jobs.WorkerConfig{Concurrency: 1})
+	worker.Handle("report", handler.Handle)
+
+	require.Eventually(t, func() bool {
+		return len(handler.Runs()) == 3
+	}, time.Second*5, time.Millisecond*20)
+	var order []int64
+	for _, run := range handler.Runs() {
+		order = append(order, run.ID)
+	}
+	require.Equal(t, []int64{urgent, early, late}, order)
+	require.Equal(t, "pending", queryState(t, wrapper, scheduled).Status)
+}
+
+func TestWorker_RetriesUntilDead(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	id := enqueue(t, wrapper, jobs.Job{Kind: "charge", MaxAttempts: 3})
+	handler := &recorder{err: func(jobs.Record) error {
+		return errors.New("card declined")
+	}}
+	worker := newWorker(t, wrapper, jobs.WorkerConfig{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	})
+	worker.Handle("charge", handler.Handle)
+
+	state := waitStatus(t, wrapper, id, "dead")
+	require.Equal(t, 3, state.Attempts)
+	require.NotNil(t, state.LastError)
+	require.Equal(t, "card declined", *state.LastError)
+	var attempts []int
+	for _, run := range handler.Runs() {
+		attempts = append(attempts, run.Attempt)
+	}
+	require.Equal(t, []int{1, 2, 3}, attempts)
+}
+
+func TestWorker_ShutdownReleasesRunningJobs(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	id := enqueue(t, wrapper, jobs.Job{Kind: "export"})
+	started := make(chan struct{})
+	worker := jobs.NewWorker(wrapper, jobs.WorkerConfig{PollInterval: time.Millisecond * 20})
+	worker.Handle("export", func(ctx context.Context, job jobs.Record) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	<-started
+	require.Equal(t, "running", queryState(t, wrapper, id).Status)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	require.ErrorIs(t, worker.Shutdown(ctx), context.DeadlineExceeded)
+	state := queryState(t, wrapper, id)
+	require.Equal(t, "pending", state.Status)
+	require.Zero(t, state.Attempts)
+}
+
+func TestWorker_RescuesJobsWithStaleHeartbeat(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	id := enqueue(t, wrapper, jobs.Job{Kind: "resize"})
+	// A worker that crashed while running the job stopped heartbeating it
+	exec(t, wrapper, "UPDATE jobs SET status = 'running', attempts = 1, heartbeat_at = now() - interval '1 hour'"+
+		" WHERE id = $1", id)
+	handler := &recorder{}
+	worker := newWorker(t, wrapper, jobs.WorkerConfig{
+		HeartbeatInterval: time.Millisecond * 50,
+		StaleAfter:        time.Millisecond * 300,
+	})
+	worker.Handle("resize", handler.Handle)
+
+	state := waitStatus(t, wrapper, id, "done")
+	require.Equal(t, 2, state.Attempts)
+	runs := handler.Runs()
+	require.Len(t, runs, 1)
+	require.Equal(t, 2, runs[0].Attempt)
+}
+
+func TestWorker_DropsResultOfRescuedAttempt(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	id := enqueue(t, wrapper, jobs.Job{Kind: "sync"})
+	release := make(chan struct{})
+	unblock := sync.OnceFunc(func() {
+		close(release)
+	})
+	started := make(chan struct{}, 1)
+	stuck := newWorker(t, wrapper, jobs.WorkerConfig{Concurrency: 1})
+	t.Cleanup(unblock)
+	stuck.Handle("sync", func(context.Context, jobs.Record) error {
+		started <- struct{}{}
+		<-release
+		return errors.New("late failure")
+	})
+	<-started
+
+	// The job is taken back from the stuck worker, as a rescue would, and run by another one
+	exec(t, wrapper, "UPDATE jobs SET status = 'pending', heartbeat_at = NULL WHERE id = $1", id)
+	other := newWorker(t, wrapper, jobs.WorkerConfig{})
+	other.Handle("sync", (&recorder{}).Handle)
+	waitStatus(t, wrapper, id, "done")
+
+	unblock()
+	time.Sleep(time.Millisecond * 100)
+	state := queryState(t, wrapper, id)
+	require.Equal(t, "done", state.Status)
+	require.Equal(t, 2, state.Attempts)
+	require.Nil(t, state.LastError)
+}
@@ -0,0 +1,162 @@
+// Package leader elects a single leader among replicas with a Postgres session advisory lock.
+//
+// The lock lives as long as the session holding it, so the pool must hand out real server sessions.
+// It does not work behind PgBouncer in transaction or statement pooling mode, where the pinned
+// connection may be backed by a different server session on every statement: connect the elector
+// directly to Postgres or through a pool in session mode.
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/termination"
+	"github.com/rs/zerolog"
+)
+
+const (
+	DefaultRetryInterval = time.Second * 5
+	DefaultRenewInterval = time.Second * 5
+)
+
+var ErrNameRequired = errors.New("leader: name is required")
+
+type Config struct {
+	// Name identifies the leadership, every candidate campaigning for the same name competes for it.
+	Name string
+	// RetryInterval is the pause between campaigns while another candidate is the leader.
+	RetryInterval time.Duration
+	// RenewInterval is how often the leader checks that it still holds the lock.
+	RenewInterval time.Duration
+	// OnElected runs in its own goroutine once leadership is acquired.
+	// Its context is canceled as soon as leadership is lost or the elector shuts down.
+	OnElected func(ctx context.Context)
+	// OnRevoked is called after OnElected returned, once leadership is gone.
+	OnRevoked func()
+	Logger    zerolog.Logger
+}
+
+type Elector interface {
+	termination.Shutdowner
+	IsLeader() bool
+}
+
+type elector struct {
+	pool      db.PgxPoolWrapper
+	config    Config
+	leader    atomic.Bool
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewElector starts campaigning for cfg.Name with a session advisory lock held on a pinned connection.
+// A lost connection drops the lock on the server, so the leader pings it every RenewInterval
+// and steps down when the ping fails, letting another candidate take over.
+func NewElector(pool db.PgxPoolWrapper, cfg Config) (Elector, error) {
+	if cfg.Name == "" {
+		return nil, ErrNameRequired
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = DefaultRetryInterval
+	}
+	if cfg.RenewInterval <= 0 {
+		cfg.RenewInterval = DefaultRenewInterval
+	}
+	e := &elector{
+		pool:    pool,
+		config:  cfg,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go e.loop()
+	return e, nil
+}
+
+func (e *elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Shutdown cancels the leadership context, waits for OnElected to return and releases the lock.
+func (e *elector) Shutdown(ctx context.Context) error {
+	e.closeOnce.Do(func() {
+		close(e.done)
+	})
+	select {
+	case <-e.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *elector) loop() {
+	defer close(e.stopped)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-e.done
+		cancel()
+	}()
+	for {
+		// The lock is tied to the background context, it is released explicitly by lead
+		lock, err := db.TryAcquireSessionLock(context.Background(), e.pool, e.config.Name)
+		if err != nil {
+			e.config.Logger.Warn().Err(err).Str("leadership", e.config.Name).Msg("leader campaign failed")
+		}
+		if lock != nil {
+			e.lead(ctx, lock)
+		}
+		select {
+		case <-e.done:
+			return
+		case <-time.After(e.config.RetryInterval):
+		}
+	}
+}
+
+// lead holds leadership until the lock is lost or ctx is canceled.
+func (e *elector) lead(ctx context.Context, lock *db.SessionLock) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	e.leader.Store(true)
+	e.config.Logger.Info().Str("leadership", e.config.Name).Msg("elected as leader")
+	var wg sync.WaitGroup
+	if e.config.OnElected != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.config.OnElected(leaderCtx)
+		}()
+	}
+	ticker := time.NewTicker(e.config.RenewInterval)
+	defer ticker.Stop()
+renew:
+	for {
+		select {
+		case <-leaderCtx.Done():
+			break renew
+		case <-ticker.C:
+			pingCtx, pingCancel := context.WithTimeout(leaderCtx, e.config.RenewInterval)
+			err := lock.Ping(pingCtx)
+			pingCancel()
+			if err != nil && leaderCtx.Err() == nil {
+				e.config.Logger.Warn().Err(err).Str("leadership", e.config.Name).Msg("leadership lost")
+				break renew
+			}
+		}
+	}
+	e.leader.Store(false)
+	cancel()
+	wg.Wait()
+	if err := lock.Release(context.Background()); err != nil {
+		e.config.Logger.Warn().Err(err).Str("leadership", e.config.Name).Msg("failed to release leadership")
+	}
+	if e.config.OnRevoked != nil {
+		e.config.OnRevoked()
+	}
+}
@@ -0,0 +1,98 @@
+package leader_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/IndexStorm/common-go/leader"
+	"github.com/stretchr/testify/require"
+)
+
+const schemaDir = "../db/testdata/migrations"
+
+type candidate struct {
+	elector leader.Elector
+	elected chan context.Context
+	revoked atomic.Int32
+}
+
+func newCandidate(t *testing.T, wrapper db.PgxPoolWrapper) *candidate {
+	c := &candidate{elected: make(chan context.Context, 1)}
+	elector, err := leader.NewElector(wrapper, leader.Config{
+		Name:          "scheduler",
+		RetryInterval: time.Millisecond * 50,
+		RenewInterval: time.Millisecond * 50,
+		OnElected: func(ctx context.Context) {
+			c.elected <- ctx
+			<-ctx.Done()
+		},
+		OnRevoked: func() {
+			c.revoked.Add(1)
+		},
+	})
+	require.NoError(t, err)
+	c.elector = elector
+	t.Cleanup(func() {
+		require.NoError(t, elector.Shutdown(context.Background()))
+	})
+	return c
+}
+
+func TestElector_SingleLeaderAndTakeover(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	first := newCandidate(t, wrapper)
+	firstCtx := waitElected(t, first)
+	require.True(t, first.elector.IsLeader())
+
+	second := newCandidate(t, wrapper)
+	// The second candidate keeps campaigning while the first renews its leadership
+	time.Sleep(time.Millisecond * 300)
+	require.False(t, second.elector.IsLeader())
+	require.True(t, first.elector.IsLeader())
+	require.NoError(t, firstCtx.Err())
+
+	require.NoError(t, first.elector.Shutdown(context.Background()))
+	require.Error(t, firstCtx.Err())
+	require.False(t, first.elector.IsLeader())
+	require.EqualValues(t, 1, first.revoked.Load())
+	waitElected(t, second)
+	require.True(t, second.elector.IsLeader())
+}
+
+func TestElector_StepsDownWhenLockIsLost(t *testing.T) {
+	pool, wrapper := dbtest.New(t, schemaDir)
+	c := newCandidate(t, wrapper)
+	leaderCtx := waitElected(t, c)
+
+	// Killing the session drops the advisory lock on the server, the next renewal notices it
+	_, err := pool.Exec(context.Background(), "SELECT pg_terminate_backend(pid) FROM pg_locks"+
+		" WHERE locktype = 'advisory' AND granted AND pid <> pg_backend_pid()")
+	require.NoError(t, err)
+	select {
+	case <-leaderCtx.Done():
+	case <-time.After(time.Second * 5):
+		t.Fatal("leadership context was not canceled")
+	}
+	require.Eventually(t, func() bool {
+		return c.revoked.Load() == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	// The lock is free again, so the candidate is re-elected on its next campaign
+	waitElected(t, c)
+	require.True(t, c.elector.IsLeader())
+}
+
+func waitElected(t *testing.T, c *candidate) context.Context {
+	t.Helper()
+	select {
+	case ctx := <-c.elected:
+		return ctx
+	case <-time.After(time.Second * 10):
+		t.Fatal("candidate was not elected")
+		return nil
+	}
+}
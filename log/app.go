@@ -0,0 +1,18 @@
+package log
+
+import (
+	"github.com/IndexStorm/common-go/config"
+	"github.com/rs/zerolog"
+)
+
+// WithAppInfo adds the application identity to every entry written by the logger.
+func WithAppInfo(logger zerolog.Logger, app config.AppInfo) zerolog.Logger {
+	ctx := logger.With().Str("service", app.Service)
+	if app.Namespace != "" {
+		ctx = ctx.Str("namespace", app.Namespace)
+	}
+	return ctx.
+		Str("version", app.Version).
+		Str("instance", app.Instance).
+		Logger()
+}
@@ -0,0 +1,55 @@
+package log_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/IndexStorm/common-go/config"
+	"github.com/IndexStorm/common-go/log"
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithAppInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		app  config.AppInfo
+		want map[string]any
+	}{
+		{
+			name: "with namespace",
+			app:  config.AppInfo{Service: "billing", Namespace: "payments", Version: "v1.2.3", Instance: "billing-0"},
+			want: map[string]any{
+				"level":     "info",
+				"message":   "started",
+				"service":   "billing",
+				"namespace": "payments",
+				"version":   "v1.2.3",
+				"instance":  "billing-0",
+			},
+		},
+		{
+			name: "without namespace",
+			app:  config.AppInfo{Service: "billing", Version: "unknown", Instance: "host"},
+			want: map[string]any{
+				"level":    "info",
+				"message":  "started",
+				"service":  "billing",
+				"version":  "unknown",
+				"instance": "host",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := log.WithAppInfo(zerolog.New(&buf), tt.app)
+			logger.Info().Msg("started")
+
+			var record map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+			require.Equal(t, tt.want, record)
+		})
+	}
+}
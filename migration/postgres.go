@@ -47,6 +47,7 @@ func (p *postgresMigrator) runMigrations(config Config) error {
 	if err != nil {
 		return fmt.Errorf("init migrate: %w", err)
 	}
+	defer migrator.Close()
 	if config.ForceVersion >= -1 {
 		err = migrator.Force(config.ForceVersion)
 		if err != nil {
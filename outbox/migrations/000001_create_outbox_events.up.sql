@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS outbox_events
+(
+    id           BIGSERIAL PRIMARY KEY,
+    topic        TEXT        NOT NULL,
+    key          TEXT        NOT NULL DEFAULT '',
+    payload      BYTEA       NOT NULL,
+    headers      JSONB       NOT NULL DEFAULT '{}',
+    status       TEXT        NOT NULL DEFAULT 'pending',
+    attempts     INTEGER     NOT NULL DEFAULT 0,
+    last_error   TEXT,
+    available_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    processed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx
+    ON outbox_events (available_at, id)
+    WHERE status = 'pending';
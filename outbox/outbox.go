@@ -0,0 +1,49 @@
+package outbox
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"time"
+)
+
+// Migrations creates the DefaultTable events table and the partial index the relay polls.
+// Run them with the iofs source of golang-migrate or copy them next to the service migrations.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+const DefaultTable = "outbox_events"
+
+const (
+	statusPending = "pending"
+	statusDone    = "done"
+	statusDead    = "dead"
+)
+
+var ErrNotInTx = errors.New("outbox: events must be written within a transaction")
+
+// Event is a message to be published once the transaction writing it commits.
+type Event struct {
+	Topic   string
+	Key     string
+	Payload []byte
+	Headers map[string]string
+	// AvailableAt delays the delivery, zero means as soon as possible.
+	AvailableAt time.Time
+}
+
+// Message is an event read back by the relay. Headers include the trace context of the writer.
+type Message struct {
+	ID        int64
+	Topic     string
+	Key       string
+	Payload   []byte
+	Headers   map[string]string
+	Attempts  int
+	CreatedAt time.Time
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,221 @@
+package outbox
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/internal/pgqueue"
+	"github.com/IndexStorm/common-go/termination"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type RelayConfig struct {
+	Table        string
+	BatchSize    int
+	PollInterval time.Duration
+	// MaxAttempts moves an event to the dead state after that many failed deliveries.
+	MaxAttempts int
+	// ClaimTimeout is how long fetched events are reserved for this relay. Events whose delivery
+	// is not stored in time, e.g. because the relay crashed, are delivered again.
+	ClaimTimeout   time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Logger         zerolog.Logger
+}
+
+type Relay interface {
+	termination.Shutdowner
+}
+
+type relay struct {
+	pool      db.PgxPoolWrapper
+	publisher Publisher
+	config    RelayConfig
+	tracer    trace.Tracer
+	queries   relayQueries
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+type relayQueries struct {
+	claim string
+	done  string
+	retry string
+	dead  string
+}
+
+// NewRelay starts polling the outbox table and delivering pending events to publisher in id order.
+// A batch is claimed with FOR UPDATE SKIP LOCKED in a statement of its own, so several relays can share
+// the table and no transaction stays open while events are published.
+// Delivery is at-least-once: publishers must tolerate duplicates.
+func NewRelay(pool db.PgxPoolWrapper, publisher Publisher, cfg RelayConfig) Relay {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	if cfg.ClaimTimeout <= 0 {
+		cfg.ClaimTimeout = time.Minute
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Minute * 10
+	}
+	table := pgqueue.SanitizeTable(cfg.Table, DefaultTable)
+	// Settling statements only apply while the claim of the relay is still the latest one
+	owned := " WHERE id = $1 AND status = '" + statusPending + "' AND attempts = $2"
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &relay{
+		pool:      pool,
+		publisher: publisher,
+		config:    cfg,
+		tracer:    otel.Tracer("github.com/IndexStorm/common-go/outbox"),
+		queries: relayQueries{
+			// The attempt is counted when claimed, a claim that expires counts as a failed delivery
+			claim: "UPDATE " + table + " SET attempts = attempts + 1, available_at = now() + $2::interval" +
+				" WHERE id IN (SELECT id FROM " + table +
+				" WHERE status = '" + statusPending + "' AND available_at <= now()" +
+				" ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED)" +
+				" RETURNING id, topic, key, payload, headers, attempts - 1, created_at",
+			done:  "UPDATE " + table + " SET status = '" + statusDone + "', processed_at = now(), last_error = NULL" + owned,
+			retry: "UPDATE " + table + " SET last_error = $3, available_at = now() + $4::interval" + owned,
+			dead:  "UPDATE " + table + " SET status = '" + statusDead + "', last_error = $3, processed_at = now()" + owned,
+		},
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go r.loop()
+	return r
+}
+
+// Shutdown stops polling and waits for the batch in flight to finish. When ctx expires first,
+// the publish in flight is canceled and the events left in the batch are delivered again once their claim expires.
+func (r *relay) Shutdown(ctx context.Context) error {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+	select {
+	case <-r.stopped:
+		return nil
+	case <-ctx.Done():
+		r.cancel()
+		return ctx.Err()
+	}
+}
+
+func (r *relay) loop() {
+	defer close(r.stopped)
+	defer r.cancel()
+	pgqueue.Poll(r.done, r.config.PollInterval, func() bool {
+		processed, err := r.processBatch(r.ctx)
+		if err != nil {
+			r.config.Logger.Error().Err(err).Msg("failed to relay outbox events")
+		}
+		// Events left over after a full batch are relayed without waiting for the next poll
+		return err == nil && processed == r.config.BatchSize
+	})
+}
+
+func (r *relay) processBatch(ctx context.Context) (int, error) {
+	conn := r.pool.GetConnectionFromCtx(ctx)
+	rows, err := conn.Query(ctx, r.queries.claim, r.config.BatchSize, r.config.ClaimTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("claim events: %w", err)
+	}
+	messages, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Message, error) {
+		var msg Message
+		err := row.Scan(&msg.ID, &msg.Topic, &msg.Key, &msg.Payload, &msg.Headers, &msg.Attempts, &msg.CreatedAt)
+		return msg, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("collect events: %w", err)
+	}
+	// RETURNING does not keep the order of the subquery
+	slices.SortFunc(messages, func(a, b Message) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	for _, msg := range messages {
+		if err = ctx.Err(); err != nil {
+			return 0, err
+		}
+		if err = r.deliver(ctx, conn, msg); err != nil {
+			return 0, err
+		}
+	}
+	return len(messages), nil
+}
+
+func (r *relay) deliver(ctx context.Context, conn db.PgxConnection, msg Message) error {
+	publishErr := r.publish(ctx, msg)
+	attempt := msg.Attempts + 1
+	var tag pgconn.CommandTag
+	var err error
+	switch {
+	case publishErr == nil:
+		tag, err = conn.Exec(ctx, r.queries.done, msg.ID, attempt)
+	case attempt >= r.config.MaxAttempts:
+		r.config.Logger.Error().Err(publishErr).Int64("event_id", msg.ID).Str("topic", msg.Topic).
+			Msg("outbox event moved to dead letter")
+		tag, err = conn.Exec(ctx, r.queries.dead, msg.ID, attempt, publishErr.Error())
+	default:
+		tag, err = conn.Exec(ctx, r.queries.retry, msg.ID, attempt, publishErr.Error(), pgqueue.Backoff(r.config.InitialBackoff, r.config.MaxBackoff, attempt))
+	}
+	if err != nil {
+		return fmt.Errorf("update event %d: %w", msg.ID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		r.config.Logger.Warn().Int64("event_id", msg.ID).Str("topic", msg.Topic).
+			Msg("outbox event claim expired before its delivery was stored, it is delivered again")
+	}
+	return nil
+}
+
+// publish runs the publisher in a span continuing the trace of the transaction that wrote the event.
+func (r *relay) publish(ctx context.Context, msg Message) (err error) {
+	// The batch context only carries cancellation, the trace is the one of the writer
+	parent := pgqueue.TracePropagator.Extract(ctx, propagation.MapCarrier(msg.Headers))
+	spanCtx, span := r.tracer.Start(parent, "publish "+msg.Topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingDestinationName(msg.Topic),
+			attribute.Int64("outbox.event.id", msg.ID),
+			attribute.Int("outbox.event.attempt", msg.Attempts+1),
+		),
+	)
+	defer span.End()
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("publisher panic: %v", rec)
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}()
+	return r.publisher.Publish(spanCtx, msg)
+}
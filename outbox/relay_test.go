@@ -0,0 +1,187 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/IndexStorm/common-go/outbox"
+	"github.com/stretchr/testify/require"
+)
+
+const schemaDir = "migrations"
+
+type delivery struct {
+	msg outbox.Message
+	at  time.Time
+}
+
+// publisher records deliveries and fails those for which fail returns an error.
+type publisher struct {
+	mu         sync.Mutex
+	deliveries []delivery
+	fail       func(msg outbox.Message) error
+}
+
+func (p *publisher) Publish(_ context.Context, msg outbox.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deliveries = append(p.deliveries, delivery{msg: msg, at: time.Now()})
+	if p.fail != nil {
+		return p.fail(msg)
+	}
+	return nil
+}
+
+func (p *publisher) Deliveries() []delivery {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]delivery(nil), p.deliveries...)
+}
+
+func startRelay(t *testing.T, wrapper db.PgxPoolWrapper, pub outbox.Publisher, cfg outbox.RelayConfig) {
+	cfg.PollInterval = time.Millisecond * 20
+	relay := outbox.NewRelay(wrapper, pub, cfg)
+	t.Cleanup(func() {
+		require.NoError(t, relay.Shutdown(context.Background()))
+	})
+}
+
+func writeEvents(t *testing.T, wrapper db.PgxPoolWrapper, events ...outbox.Event) {
+	writer := outbox.NewWriter(wrapper, "")
+	err := wrapper.RunInTx(context.Background(), func(ctx context.Context) error {
+		return writer.Write(ctx, events...)
+	})
+	require.NoError(t, err)
+}
+
+type eventState struct {
+	Status    string
+	Attempts  int
+	LastError *string
+}
+
+func waitStatus(t *testing.T, wrapper db.PgxPoolWrapper, id int64, status string) eventState {
+	var state eventState
+	require.Eventually(t, func() bool {
+		var err error
+		state, err = db.QueryOne[eventState](context.Background(), wrapper,
+			"SELECT status, attempts, last_error FROM outbox_events WHERE id = $1", id)
+		require.NoError(t, err)
+		return state.Status == status
+	}, time.Second*5, time.Millisecond*20)
+	return state
+}
+
+func TestRelay_DeliversInIDOrder(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	topics := []string{"a", "b", "c", "d", "e"}
+	for _, topic := range topics {
+		writeEvents(t, wrapper, outbox.Event{Topic: topic, Key: "k", Payload: []byte(topic)})
+	}
+	pub := &publisher{}
+	startRelay(t, wrapper, pub, outbox.RelayConfig{BatchSize: 2})
+
+	require.Eventually(t, func() bool {
+		return len(pub.Deliveries()) == len(topics)
+	}, time.Second*5, time.Millisecond*20)
+	for i, d := range pub.Deliveries() {
+		require.Equal(t, int64(i+1), d.msg.ID)
+		require.Equal(t, topics[i], d.msg.Topic)
+		require.Equal(t, []byte(topics[i]), d.msg.Payload)
+		require.Zero(t, d.msg.Attempts)
+	}
+	state := waitStatus(t, wrapper, int64(len(topics)), "done")
+	require.Equal(t, 1, state.Attempts)
+	require.Nil(t, state.LastError)
+}
+
+func TestRelay_RetriesWithBackoff(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	writeEvents(t, wrapper, outbox.Event{Topic: "orders", Payload: []byte("{}")})
+	pub := &publisher{fail: func(msg outbox.Message) error {
+		if msg.Attempts == 0 {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	}}
+	backoff := time.Millisecond * 300
+	startRelay(t, wrapper, pub, outbox.RelayConfig{InitialBackoff: backoff, MaxBackoff: backoff})
+
+	state := waitStatus(t, wrapper, 1, "done")
+	require.Equal(t, 2, state.Attempts)
+	deliveries := pub.Deliveries()
+	require.Len(t, deliveries, 2)
+	require.Equal(t, []int{0, 1}, []int{deliveries[0].msg.Attempts, deliveries[1].msg.Attempts})
+	require.GreaterOrEqual(t, deliveries[1].at.Sub(deliveries[0].at), backoff/2)
+}
+
+func TestRelay_DeadAfterMaxAttempts(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	writeEvents(t, wrapper, outbox.Event{Topic: "orders", Payload: []byte("{}")})
+	pub := &publisher{fail: func(outbox.Message) error {
+		return errors.New("rejected")
+	}}
+	startRelay(t, wrapper, pub, outbox.RelayConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	})
+
+	state := waitStatus(t, wrapper, 1, "dead")
+	require.Equal(t, 3, state.Attempts)
+	require.NotNil(t, state.LastError)
+	require.Equal(t, "rejected", *state.LastError)
+	// A dead event is never claimed again
+	time.Sleep(time.Millisecond * 100)
+	require.Len(t, pub.Deliveries(), 3)
+}
+
+func TestRelay_RedeliversExpiredClaim(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	writeEvents(t, wrapper, outbox.Event{Topic: "orders", Payload: []byte("{}")})
+	// A relay that crashed after claiming the event leaves it pending until the claim expires
+	_, err := wrapper.GetConnectionFromCtx(context.Background()).Exec(context.Background(),
+		"UPDATE outbox_events SET attempts = attempts + 1, available_at = now() + interval '300 milliseconds'")
+	require.NoError(t, err)
+	pub := &publisher{}
+	startRelay(t, wrapper, pub, outbox.RelayConfig{})
+
+	time.Sleep(time.Millisecond * 100)
+	require.Empty(t, pub.Deliveries())
+	state := waitStatus(t, wrapper, 1, "done")
+	require.Equal(t, 2, state.Attempts)
+	deliveries := pub.Deliveries()
+	require.Len(t, deliveries, 1)
+	require.Equal(t, 1, deliveries[0].msg.Attempts)
+}
+
+func TestRelay_IgnoresSettlingOfExpiredClaim(t *testing.T) {
+	_, wrapper := dbtest.New(t, schemaDir)
+	writeEvents(t, wrapper, outbox.Event{Topic: "orders", Payload: []byte("{}")})
+	release := make(chan struct{})
+	slow := &publisher{fail: func(outbox.Message) error {
+		<-release
+		return errors.New("late failure")
+	}}
+	startRelay(t, wrapper, slow, outbox.RelayConfig{ClaimTimeout: time.Millisecond * 100})
+	unblock := sync.OnceFunc(func() {
+		close(release)
+	})
+	t.Cleanup(unblock)
+	require.Eventually(t, func() bool {
+		return len(slow.Deliveries()) == 1
+	}, time.Second*5, time.Millisecond*20)
+
+	// The second relay delivers the event again once the claim of the stuck one expired
+	startRelay(t, wrapper, &publisher{}, outbox.RelayConfig{})
+	waitStatus(t, wrapper, 1, "done")
+	unblock()
+	time.Sleep(time.Millisecond * 100)
+	state := waitStatus(t, wrapper, 1, "done")
+	require.Nil(t, state.LastError)
+}
@@ -0,0 +1,50 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/IndexStorm/common-go/db"
+	"github.com/IndexStorm/common-go/internal/pgqueue"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+type Writer interface {
+	// Write stores events in the transaction carried by ctx, so they become visible to the relay only on commit.
+	Write(ctx context.Context, events ...Event) error
+}
+
+type writer struct {
+	pool  db.PgxPoolWrapper
+	query string
+}
+
+func NewWriter(pool db.PgxPoolWrapper, table string) Writer {
+	return &writer{
+		pool: pool,
+		query: "INSERT INTO " + pgqueue.SanitizeTable(table, DefaultTable) + " (topic, key, payload, headers, available_at) " +
+			"VALUES ($1, $2, $3, $4, COALESCE($5, now()))",
+	}
+}
+
+func (w *writer) Write(ctx context.Context, events ...Event) error {
+	if _, ok := ctx.Value(db.PgxConnectionCtxKey{}).(pgx.Tx); !ok {
+		return ErrNotInTx
+	}
+	conn := w.pool.GetConnectionFromCtx(ctx)
+	for _, event := range events {
+		headers := make(map[string]string, len(event.Headers)+2)
+		maps.Copy(headers, event.Headers)
+		pgqueue.TracePropagator.Inject(ctx, propagation.MapCarrier(headers))
+		var availableAt any
+		if !event.AvailableAt.IsZero() {
+			availableAt = event.AvailableAt
+		}
+		if _, err := conn.Exec(ctx, w.query, event.Topic, event.Key, event.Payload, headers, availableAt); err != nil {
+			return fmt.Errorf("insert event %s: %w", event.Topic, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package outbox_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IndexStorm/common-go/db/dbtest"
+	"github.com/IndexStorm/common-go/outbox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter_RequiresTransaction(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	writer := outbox.NewWriter(fake, "")
+
+	err := writer.Write(context.Background(), outbox.Event{Topic: "orders", Payload: []byte("{}")})
+	require.ErrorIs(t, err, outbox.ErrNotInTx)
+	require.Empty(t, fake.Statements())
+}
+
+func TestWriter_InsertsInTransaction(t *testing.T) {
+	fake := dbtest.NewFake(t)
+	writer := outbox.NewWriter(fake, "")
+	fake.Expect(dbtest.Regex(`^INSERT INTO "outbox_events" `)).
+		WithArgs("orders", "42", []byte("{}"), dbtest.AnyArg, nil)
+
+	err := fake.RunInTx(context.Background(), func(ctx context.Context) error {
+		return writer.Write(ctx, outbox.Event{Topic: "orders", Key: "42", Payload: []byte("{}")})
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, fake.Commits())
+}
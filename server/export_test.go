@@ -0,0 +1,7 @@
+package server
+
+import "net/http"
+
+func HTTPServer(s Server) *http.Server {
+	return s.(*httpServer).server
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/IndexStorm/common-go/config"
+	"github.com/IndexStorm/common-go/termination"
+)
+
+var ErrTLSKeyPairIncomplete = errors.New("server: both TLS cert and key files are required")
+
+type Server interface {
+	termination.Shutdowner
+	// ListenAndServe blocks until the server stops. It returns nil after a graceful Shutdown.
+	ListenAndServe() error
+	Serve(listener net.Listener) error
+}
+
+type httpServer struct {
+	server *http.Server
+	tls    bool
+}
+
+func NewHTTPServer(cfg config.Server, handler http.Handler) (Server, error) {
+	server := &http.Server{
+		Addr:              cfg.ListenAddress,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, ErrTLSKeyPairIncomplete
+	}
+	useTLS := cfg.TLSCertFile != ""
+	if useTLS {
+		reloader, err := newCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSReloadInterval)
+		if err != nil {
+			return nil, fmt.Errorf("load tls key pair: %w", err)
+		}
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+	}
+	if cfg.H2C {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(useTLS)
+		protocols.SetUnencryptedHTTP2(true)
+		server.Protocols = protocols
+	}
+	return &httpServer{server: server, tls: useTLS}, nil
+}
+
+func (s *httpServer) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", s.server.Addr, err)
+	}
+	return s.Serve(listener)
+}
+
+func (s *httpServer) Serve(listener net.Listener) error {
+	var err error
+	if s.tls {
+		// Certificates are served by TLSConfig.GetCertificate
+		err = s.server.ServeTLS(listener, "", "")
+	} else {
+		err = s.server.Serve(listener)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *httpServer) Shutdown(ctx context.Context) error {
+	if err := s.server.Shutdown(ctx); err != nil {
+		_ = s.server.Close()
+		return fmt.Errorf("shutdown http server: %w", err)
+	}
+	return nil
+}
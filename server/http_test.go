@@ -0,0 +1,132 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IndexStorm/common-go/config"
+	"github.com/IndexStorm/common-go/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPServer_Timeouts(t *testing.T) {
+	cfg := config.Server{
+		ListenAddress:     "127.0.0.1:0",
+		ReadTimeout:       time.Second,
+		ReadHeaderTimeout: time.Second * 2,
+		WriteTimeout:      time.Second * 3,
+		IdleTimeout:       time.Second * 4,
+		MaxHeaderBytes:    1024,
+	}
+	srv, err := server.NewHTTPServer(cfg, http.NotFoundHandler())
+	require.NoError(t, err)
+
+	httpServer := server.HTTPServer(srv)
+	require.Equal(t, cfg.ListenAddress, httpServer.Addr)
+	require.Equal(t, cfg.ReadTimeout, httpServer.ReadTimeout)
+	require.Equal(t, cfg.ReadHeaderTimeout, httpServer.ReadHeaderTimeout)
+	require.Equal(t, cfg.WriteTimeout, httpServer.WriteTimeout)
+	require.Equal(t, cfg.IdleTimeout, httpServer.IdleTimeout)
+	require.Equal(t, cfg.MaxHeaderBytes, httpServer.MaxHeaderBytes)
+	require.Nil(t, httpServer.TLSConfig)
+	require.Nil(t, httpServer.Protocols)
+}
+
+func TestNewHTTPServer_IncompleteKeyPair(t *testing.T) {
+	_, err := server.NewHTTPServer(config.Server{TLSCertFile: "cert.pem"}, http.NotFoundHandler())
+	require.ErrorIs(t, err, server.ErrTLSKeyPairIncomplete)
+}
+
+func TestNewHTTPServer_H2C(t *testing.T) {
+	srv, err := server.NewHTTPServer(config.Server{H2C: true}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+	}))
+	require.NoError(t, err)
+	addr := serve(t, srv)
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+	resp, err := client.Get("http://" + addr)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, "HTTP/2.0", resp.Header.Get("X-Proto"))
+}
+
+func TestNewHTTPServer_ReloadsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeKeyPair(t, certFile, keyFile, 1, time.Now().Add(-time.Minute))
+
+	srv, err := server.NewHTTPServer(config.Server{
+		TLSCertFile:       certFile,
+		TLSKeyFile:        keyFile,
+		TLSReloadInterval: time.Millisecond,
+	}, http.NotFoundHandler())
+	require.NoError(t, err)
+	addr := serve(t, srv)
+	require.EqualValues(t, 1, servedSerial(t, addr))
+
+	writeKeyPair(t, certFile, keyFile, 2, time.Now())
+	time.Sleep(time.Millisecond * 5)
+	require.EqualValues(t, 2, servedSerial(t, addr))
+}
+
+func serve(t *testing.T, srv server.Server) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, srv.Shutdown(context.Background()))
+		require.NoError(t, <-done)
+	})
+	return listener.Addr().String()
+}
+
+func servedSerial(t *testing.T, addr string) int64 {
+	t.Helper()
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+}
+
+// writeKeyPair writes a self-signed pair with the given serial and sets both files to modTime.
+func writeKeyPair(t *testing.T, certFile string, keyFile string, serial int64, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	for _, file := range []string{certFile, keyFile} {
+		require.NoError(t, os.Chtimes(file, modTime, modTime))
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader re-reads the key pair when the files change, which lets cert-manager style
+// rotations take effect without a restart. Files are checked at most once per interval.
+type certReloader struct {
+	certFile  string
+	keyFile   string
+	interval  time.Duration
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newCertReloader(certFile string, keyFile string, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := r.reload(time.Now()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if r.interval > 0 && now.Sub(r.checkedAt) >= r.interval {
+		// Keep serving the previous certificate if the new pair is not readable yet
+		_ = r.reloadLocked(now)
+	}
+	return r.cert, nil
+}
+
+func (r *certReloader) reload(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reloadLocked(now)
+}
+
+func (r *certReloader) reloadLocked(now time.Time) error {
+	r.checkedAt = now
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	if r.cert != nil && !modTime.After(r.modTime) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load x509 key pair: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("stat %s: %w", file, err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
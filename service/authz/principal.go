@@ -0,0 +1,21 @@
+package authz
+
+import "context"
+
+// Principal is the authenticated caller a request is served for.
+type Principal struct {
+	UserID   string
+	TenantID string
+	Roles    []string
+}
+
+type principalCtxKey struct{}
+
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalCtxKey{}).(Principal)
+	return principal, ok
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -35,7 +37,10 @@ type OtelOption interface {
 }
 
 func OtelInitFromEnv(ctx context.Context, appConfig config.AppInfo, opts ...OtelOption) error {
-	otelConfig := config.TryParseOpenTelemetry()
+	otelConfig, err := config.ParseOpenTelemetry()
+	if err != nil {
+		return fmt.Errorf("parse config: %w", err)
+	}
 	if otelConfig == nil {
 		OtelInitNoop()
 		return nil
@@ -163,10 +168,8 @@ func otelInitTraceProvider(ctx context.Context, cfg OtelConfig) error {
 		if cfg.Trace.Insecure {
 			opts = append(opts, otlptracehttp.WithInsecure())
 		}
-		if cfg.Trace.Authorization != "" {
-			opts = append(opts, otlptracehttp.WithHeaders(map[string]string{
-				"Authorization": cfg.Trace.Authorization,
-			}))
+		if headers := otelExporterHeaders(cfg.Trace.Headers, cfg.Trace.Authorization); len(headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(headers))
 		}
 		exp, err := otlptracehttp.New(ctx, opts...)
 		if err != nil {
@@ -180,10 +183,8 @@ func otelInitTraceProvider(ctx context.Context, cfg OtelConfig) error {
 		if cfg.Trace.Insecure {
 			opts = append(opts, otlptracegrpc.WithInsecure())
 		}
-		if cfg.Trace.Authorization != "" {
-			opts = append(opts, otlptracegrpc.WithHeaders(map[string]string{
-				"Authorization": cfg.Trace.Authorization,
-			}))
+		if headers := otelExporterHeaders(cfg.Trace.Headers, cfg.Trace.Authorization); len(headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(headers))
 		}
 		exp, err := otlptracegrpc.New(ctx, opts...)
 		if err != nil {
@@ -193,11 +194,16 @@ func otelInitTraceProvider(ctx context.Context, cfg OtelConfig) error {
 	} else {
 		return fmt.Errorf("invalid trace export method: %s", cfg.Trace.Method)
 	}
+	sampler, err := otelSampler(cfg.Trace.Sampler, cfg.Trace.SamplerArg)
+	if err != nil {
+		_ = exporter.Shutdown(ctx)
+		return fmt.Errorf("init trace sampler: %w", err)
+	}
 	bsp := sdktrace.NewBatchSpanProcessor(exporter)
 	tp := sdktrace.NewTracerProvider(
 		sdktrace.WithIDGenerator(&lockFreeIdGenerator{}),
 		sdktrace.WithResource(cfg.resource),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSampler(sampler),
 		sdktrace.WithSpanProcessor(bsp),
 	)
 	otel.SetTracerProvider(tp)
@@ -213,10 +219,8 @@ func otelInitMeterProvider(ctx context.Context, cfg OtelConfig) error {
 		if cfg.Meter.Insecure {
 			opts = append(opts, otlpmetrichttp.WithInsecure())
 		}
-		if cfg.Meter.Authorization != "" {
-			opts = append(opts, otlpmetrichttp.WithHeaders(map[string]string{
-				"Authorization": cfg.Meter.Authorization,
-			}))
+		if headers := otelExporterHeaders(cfg.Meter.Headers, cfg.Meter.Authorization); len(headers) > 0 {
+			opts = append(opts, otlpmetrichttp.WithHeaders(headers))
 		}
 		exp, err := otlpmetrichttp.New(ctx, opts...)
 		if err != nil {
@@ -230,10 +234,8 @@ func otelInitMeterProvider(ctx context.Context, cfg OtelConfig) error {
 		if cfg.Meter.Insecure {
 			opts = append(opts, otlpmetricgrpc.WithInsecure())
 		}
-		if cfg.Meter.Authorization != "" {
-			opts = append(opts, otlpmetricgrpc.WithHeaders(map[string]string{
-				"Authorization": cfg.Meter.Authorization,
-			}))
+		if headers := otelExporterHeaders(cfg.Meter.Headers, cfg.Meter.Authorization); len(headers) > 0 {
+			opts = append(opts, otlpmetricgrpc.WithHeaders(headers))
 		}
 		exp, err := otlpmetricgrpc.New(ctx, opts...)
 		if err != nil {
@@ -255,3 +257,54 @@ func otelInitMeterProvider(ctx context.Context, cfg OtelConfig) error {
 	otel.SetMeterProvider(mp)
 	return nil
 }
+
+// otelExporterHeaders merges configured headers with the dedicated authorization value,
+// the latter wins when both define Authorization.
+func otelExporterHeaders(headers config.OpenTelemetryHeaders, authorization string) map[string]string {
+	merged := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		merged[k] = v
+	}
+	if authorization != "" {
+		merged["Authorization"] = authorization
+	}
+	return merged
+}
+
+// otelSampler maps OTEL_TRACES_SAMPLER values onto SDK samplers. Empty name keeps sampling everything.
+func otelSampler(name string, arg string) (sdktrace.Sampler, error) {
+	ratio := func() (float64, error) {
+		if arg == "" {
+			return 1, nil
+		}
+		value, err := strconv.ParseFloat(arg, 64)
+		if err != nil || value < 0 || value > 1 {
+			return 0, fmt.Errorf("invalid sampler ratio: %q", arg)
+		}
+		return value, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "always_on":
+		return sdktrace.AlwaysSample(), nil
+	case "always_off":
+		return sdktrace.NeverSample(), nil
+	case "traceidratio":
+		value, err := ratio()
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.TraceIDRatioBased(value), nil
+	case "parentbased_always_on":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case "parentbased_traceidratio":
+		value, err := ratio()
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(value)), nil
+	default:
+		return nil, fmt.Errorf("unsupported sampler: %q", name)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/IndexStorm/common-go/db/pgerr"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"go.opentelemetry.io/otel"
@@ -110,15 +111,31 @@ func (t *SqlTracer) TraceConnectEnd(ctx context.Context, data pgx.TraceConnectEn
 	span.End()
 }
 
-func (t *SqlTracer) recordError(span trace.Span, err error) {
+// TraceTxAttempt records a RunInTx attempt as an event of the span active in ctx.
+func (t *SqlTracer) TraceTxAttempt(ctx context.Context, attempt int, err error) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	attrs := []attribute.KeyValue{attribute.Int("db.transaction.attempt", attempt)}
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, err.Error())
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
-			span.SetAttributes(attribute.String("db.response.error.code", pgErr.Code))
+			attrs = append(attrs, attribute.String("db.response.error.code", pgErr.Code))
 		}
-		span.SetAttributes(attribute.String("db.response.error.summary", err.Error()))
+		if name := pgerr.Name(err); name != "" {
+			attrs = append(attrs, semconv.ErrorTypeKey.String(name))
+		}
+		attrs = append(attrs, attribute.String("db.response.error.summary", err.Error()))
+	}
+	span.AddEvent("db.transaction.attempt", trace.WithAttributes(attrs...))
+}
+
+func (t *SqlTracer) recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		t.recordErrorAttributes(span, err)
 	}
 }
 
@@ -126,12 +143,22 @@ func (t *SqlTracer) recordQueryError(span trace.Span, err error) {
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			span.SetAttributes(attribute.String("db.response.error.code", pgErr.Code))
+		t.recordErrorAttributes(span, err)
+	}
+}
+
+func (t *SqlTracer) recordErrorAttributes(span trace.Span, err error) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		span.SetAttributes(attribute.String("db.response.error.code", pgErr.Code))
+		if pgErr.ConstraintName != "" {
+			span.SetAttributes(attribute.String("db.response.error.constraint", pgErr.ConstraintName))
 		}
-		span.SetAttributes(attribute.String("db.response.error.summary", err.Error()))
 	}
+	if name := pgerr.Name(err); name != "" {
+		span.SetAttributes(semconv.ErrorTypeKey.String(name))
+	}
+	span.SetAttributes(attribute.String("db.response.error.summary", err.Error()))
 }
 
 func (t *SqlTracer) recordRows(span trace.Span, err error, rows int64) {
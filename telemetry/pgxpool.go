@@ -0,0 +1,104 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+)
+
+const (
+	pgxPoolConnectionStateKey  = attribute.Key("db.client.connection.state")
+	pgxPoolConnectionStateIdle = "idle"
+	pgxPoolConnectionStateUsed = "used"
+)
+
+// RegisterPgxPoolMetrics exports pool.Stat() through the global meter provider.
+// Instruments are observed on every collection, so the registration may happen before OtelInit.
+// Unregister the returned registration when the pool is closed before the process exits.
+func RegisterPgxPoolMetrics(pool *pgxpool.Pool, attrs ...attribute.KeyValue) (metric.Registration, error) {
+	meter := otel.Meter("github.com/IndexStorm/common-go/pgxpool",
+		metric.WithInstrumentationVersion("v1.0.0"),
+		metric.WithSchemaURL(semconv.SchemaURL),
+	)
+	var errs []error
+	collect := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	connCount, err := meter.Int64ObservableUpDownCounter("db.client.connection.count",
+		metric.WithDescription("Number of connections that are currently in state described by the state attribute."),
+		metric.WithUnit("{connection}"))
+	collect(err)
+	connTotal, err := meter.Int64ObservableUpDownCounter("db.client.connection.total",
+		metric.WithDescription("Total number of connections in the pool, including the ones being constructed."),
+		metric.WithUnit("{connection}"))
+	collect(err)
+	connMax, err := meter.Int64ObservableUpDownCounter("db.client.connection.max",
+		metric.WithDescription("The maximum number of open connections allowed."),
+		metric.WithUnit("{connection}"))
+	collect(err)
+	acquireCount, err := meter.Int64ObservableCounter("db.client.connection.acquire.count",
+		metric.WithDescription("Cumulative count of successful acquires from the pool."),
+		metric.WithUnit("{acquire}"))
+	collect(err)
+	acquireDuration, err := meter.Float64ObservableCounter("db.client.connection.acquire.duration",
+		metric.WithDescription("Total duration of all successful acquires from the pool."),
+		metric.WithUnit("s"))
+	collect(err)
+	emptyAcquireCount, err := meter.Int64ObservableCounter("db.client.connection.acquire.empty.count",
+		metric.WithDescription("Cumulative count of successful acquires that waited for a connection because the pool was empty."),
+		metric.WithUnit("{acquire}"))
+	collect(err)
+	canceledAcquireCount, err := meter.Int64ObservableCounter("db.client.connection.acquire.canceled.count",
+		metric.WithDescription("Cumulative count of acquires canceled by a context."),
+		metric.WithUnit("{acquire}"))
+	collect(err)
+	newConnsCount, err := meter.Int64ObservableCounter("db.client.connection.created.count",
+		metric.WithDescription("Cumulative count of new connections opened."),
+		metric.WithUnit("{connection}"))
+	collect(err)
+	lifetimeDestroyCount, err := meter.Int64ObservableCounter("db.client.connection.lifetime_destroyed.count",
+		metric.WithDescription("Cumulative count of connections destroyed because they exceeded MaxConnLifetime."),
+		metric.WithUnit("{connection}"))
+	collect(err)
+	idleDestroyCount, err := meter.Int64ObservableCounter("db.client.connection.idle_destroyed.count",
+		metric.WithDescription("Cumulative count of connections destroyed because they exceeded MaxConnIdleTime."),
+		metric.WithUnit("{connection}"))
+	collect(err)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("create pgxpool instruments: %w", errors.Join(errs...))
+	}
+	baseAttrs := metric.WithAttributes(attrs...)
+	idleAttrs := metric.WithAttributes(append(attrs[:len(attrs):len(attrs)], pgxPoolConnectionStateKey.String(pgxPoolConnectionStateIdle))...)
+	usedAttrs := metric.WithAttributes(append(attrs[:len(attrs):len(attrs)], pgxPoolConnectionStateKey.String(pgxPoolConnectionStateUsed))...)
+	registration, err := meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		stat := pool.Stat()
+		o.ObserveInt64(connCount, int64(stat.IdleConns()), idleAttrs)
+		o.ObserveInt64(connCount, int64(stat.AcquiredConns()), usedAttrs)
+		o.ObserveInt64(connTotal, int64(stat.TotalConns()), baseAttrs)
+		o.ObserveInt64(connMax, int64(stat.MaxConns()), baseAttrs)
+		o.ObserveInt64(acquireCount, stat.AcquireCount(), baseAttrs)
+		o.ObserveFloat64(acquireDuration, stat.AcquireDuration().Seconds(), baseAttrs)
+		o.ObserveInt64(emptyAcquireCount, stat.EmptyAcquireCount(), baseAttrs)
+		o.ObserveInt64(canceledAcquireCount, stat.CanceledAcquireCount(), baseAttrs)
+		o.ObserveInt64(newConnsCount, stat.NewConnsCount(), baseAttrs)
+		o.ObserveInt64(lifetimeDestroyCount, stat.MaxLifetimeDestroyCount(), baseAttrs)
+		o.ObserveInt64(idleDestroyCount, stat.MaxIdleDestroyCount(), baseAttrs)
+		return nil
+	},
+		connCount, connTotal, connMax,
+		acquireCount, acquireDuration, emptyAcquireCount, canceledAcquireCount,
+		newConnsCount, lifetimeDestroyCount, idleDestroyCount,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("register pgxpool callback: %w", err)
+	}
+	return registration, nil
+}
@@ -0,0 +1,82 @@
+package telemetry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IndexStorm/common-go/telemetry"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRegisterPgxPoolMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	previous := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() {
+		otel.SetMeterProvider(previous)
+	})
+	// The pool connects lazily, so its statistics are known without a server
+	pool, err := pgxpool.New(context.Background(), "postgres://user@127.0.0.1:1/app?pool_max_conns=7")
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	namespace := attribute.String("db.namespace", "app")
+
+	registration, err := telemetry.RegisterPgxPoolMetrics(pool, namespace)
+	require.NoError(t, err)
+	metrics := collect(t, reader)
+	require.ElementsMatch(t, []string{
+		"db.client.connection.count",
+		"db.client.connection.total",
+		"db.client.connection.max",
+		"db.client.connection.acquire.count",
+		"db.client.connection.acquire.duration",
+		"db.client.connection.acquire.empty.count",
+		"db.client.connection.acquire.canceled.count",
+		"db.client.connection.created.count",
+		"db.client.connection.lifetime_destroyed.count",
+		"db.client.connection.idle_destroyed.count",
+	}, mapsKeys(metrics))
+
+	maxConns := metrics["db.client.connection.max"].Data.(metricdata.Sum[int64]).DataPoints
+	require.Len(t, maxConns, 1)
+	require.EqualValues(t, 7, maxConns[0].Value)
+	require.Equal(t, attribute.NewSet(namespace), maxConns[0].Attributes)
+
+	states := map[string]int64{}
+	for _, point := range metrics["db.client.connection.count"].Data.(metricdata.Sum[int64]).DataPoints {
+		state, ok := point.Attributes.Value("db.client.connection.state")
+		require.True(t, ok)
+		states[state.AsString()] = point.Value
+	}
+	require.Equal(t, map[string]int64{"idle": 0, "used": 0}, states)
+	acquired := metrics["db.client.connection.acquire.count"].Data.(metricdata.Sum[int64])
+	require.True(t, acquired.IsMonotonic)
+
+	require.NoError(t, registration.Unregister())
+	require.Empty(t, collect(t, reader))
+}
+
+func collect(t *testing.T, reader sdkmetric.Reader) map[string]metricdata.Metrics {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	metrics := make(map[string]metricdata.Metrics)
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+	return metrics
+}
+
+func mapsKeys(metrics map[string]metricdata.Metrics) []string {
+	keys := make([]string, 0, len(metrics))
+	for name := range metrics {
+		keys = append(keys, name)
+	}
+	return keys
+}